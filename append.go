@@ -0,0 +1,158 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+)
+
+const id3v1Size = 128
+
+// EncodeAppended writes the tag followed by a footer and without
+// padding, which is the form required for tags at the end of a file
+// or stream.
+func (t *Tag) EncodeAppended(w io.Writer) error {
+	return t.encode(w, true, t.saveOptions())
+}
+
+// readFooter reads an ID3v2 footer. It expects the reader to be
+// seeked to the beginning of the footer.
+func readFooter(r io.Reader) (TagHeader, error) {
+	b := make([]byte, tagFooterSize)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return TagHeader{}, err
+	}
+
+	if !bytes.HasPrefix(b, footerByte) {
+		var magic [3]byte
+		copy(magic[:], b)
+		return TagHeader{}, notATagHeader{magic}
+	}
+
+	// Apart from the identifier, footers are identical to headers.
+	copy(b, id3byte)
+	return readHeader(bytes.NewReader(b))
+}
+
+// id3v1Offset returns the offset of the ID3v1 tag at the end of the
+// file. If there is none, it returns the size of the file.
+func (f *File) id3v1Offset() (int64, error) {
+	if f.fileSize-f.audioStart < id3v1Size {
+		return f.fileSize, nil
+	}
+
+	b := make([]byte, 3)
+	_, err := f.src.ReadAt(b, f.fileSize-id3v1Size)
+	if err != nil {
+		return 0, err
+	}
+
+	if string(b) == "TAG" {
+		return f.fileSize - id3v1Size, nil
+	}
+
+	return f.fileSize, nil
+}
+
+// locateAppendedTag looks for a tag at the end of the file, identified
+// by its footer, either at the very end or in front of an ID3v1 tag.
+// It returns the offsets at which the tag starts and ends. If there is
+// no such tag, both offsets point to the end of the audio data.
+func (f *File) locateAppendedTag() (start, end int64, err error) {
+	end, err = f.id3v1Offset()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if end-f.audioStart < tagHeaderSize+tagFooterSize {
+		return end, end, nil
+	}
+
+	footer, err := readFooter(io.NewSectionReader(f.src, end-tagFooterSize, tagFooterSize))
+	if err != nil {
+		if _, ok := err.(notATagHeader); ok {
+			return end, end, nil
+		}
+		if _, ok := err.(UnsupportedVersion); ok {
+			return end, end, nil
+		}
+		return 0, 0, err
+	}
+
+	start = end - tagFooterSize - int64(footer.Size) - tagHeaderSize
+	if start < f.audioStart {
+		return end, end, nil
+	}
+
+	_, err = readHeader(io.NewSectionReader(f.src, start, tagHeaderSize))
+	if err != nil {
+		f.logger().Warn("found footer without matching header", "offset", start, "action", "ignored footer")
+		return end, end, nil
+	}
+
+	return start, end, nil
+}
+
+// mergeAppendedTag parses the tag at the end of the file. If the file
+// has no tag at its beginning, the appended tag will be used instead.
+// Otherwise its frames will be merged, with the frames of the first
+// tag taking precedence.
+func (f *File) mergeAppendedTag() error {
+	if f.audioEnd == f.trailerStart {
+		return nil
+	}
+
+	tag, err := parse(namedReader{io.NewSectionReader(f.src, f.audioEnd, f.trailerStart-f.audioEnd), f.name}, f.opts)
+	if err != nil {
+		return err
+	}
+
+	if !f.HasTag() {
+		f.Tag = tag
+		f.Append = true
+		return nil
+	}
+
+	f.logger().Debug("merging appended tag", "action", "merged")
+	f.mergeFrames(tag)
+	return nil
+}
+
+// saveAppended replaces the tag at the end of the file, or appends a
+// new one. It must only be used for files without a tag at their
+// beginning.
+func (f *File) saveAppended(framesSize int, opts Options) error {
+	_, err := f.trailer.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+
+	// Encode everything following the audio data first, so that a
+	// failure doesn't truncate the file.
+	buf := new(bytes.Buffer)
+	err = f.encode(buf, true, opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(buf, f.trailer)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.f.WriteAt(buf.Bytes(), f.audioEnd)
+	if err != nil {
+		return err
+	}
+	f.fileSize = f.audioEnd + int64(buf.Len())
+	err = f.f.Truncate(f.fileSize)
+	if err != nil {
+		return err
+	}
+	err = f.f.Sync()
+	if err != nil {
+		return err
+	}
+
+	f.setSavedHeader(framesSize, opts)
+	return f.layout(false)
+}
@@ -0,0 +1,95 @@
+package id3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, data []byte) string {
+	name := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return name
+}
+
+func TestAppendedTag(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xAA}, 1000)
+	id3v1 := append([]byte("TAG"), make([]byte, id3v1Size-3)...)
+
+	for _, withID3v1 := range []bool{false, true} {
+		tag := NewTag()
+		tag.SetTitle("Appended")
+		buf := new(bytes.Buffer)
+		buf.Write(audio)
+		if err := tag.EncodeAppended(buf); err != nil {
+			t.Fatal(err)
+		}
+		if withID3v1 {
+			buf.Write(id3v1)
+		}
+		name := writeTestFile(t, buf.Bytes())
+
+		f, err := Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.Append || !f.Header.Flags.Footer() {
+			t.Fatalf("Appended tag not detected (ID3v1: %t)", withID3v1)
+		}
+		if f.Title() != "Appended" {
+			t.Fatalf("Title is %q, expected %q", f.Title(), "Appended")
+		}
+
+		f.SetAlbum("Album")
+		if err := f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, audio) {
+			t.Fatal("Audio data was modified")
+		}
+		if withID3v1 && !bytes.HasSuffix(data, id3v1) {
+			t.Fatal("ID3v1 tag was not preserved")
+		}
+
+		f, err = Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Album() != "Album" || f.Title() != "Appended" {
+			t.Fatalf("Unexpected tag after saving: %q, %q", f.Title(), f.Album())
+		}
+
+		// Move the tag to the beginning of the file
+		f.Append = false
+		if err := f.Save(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		data, err = os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := readHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest := data[tagHeaderSize+header.Size:]
+		if withID3v1 {
+			rest = bytes.TrimSuffix(rest, id3v1)
+		}
+		if !bytes.Equal(rest, audio) {
+			t.Fatal("File contains more than the prepended tag, audio and ID3v1 tag")
+		}
+	}
+}
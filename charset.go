@@ -0,0 +1,334 @@
+package id3
+
+import (
+	"bytes"
+	"unicode"
+	utf8pkg "unicode/utf8"
+)
+
+// A CharsetDecoder converts text stored in a legacy charset to UTF-8.
+// The decoders of golang.org/x/text/encoding, like
+// japanese.ShiftJIS.NewDecoder(), implement it.
+type CharsetDecoder interface {
+	Bytes(b []byte) ([]byte, error)
+}
+
+// singleByteCharset maps the bytes 0x80-0xFF of a single byte charset
+// to runes. Bytes below 0x80 are ASCII, and bytes beyond the table
+// or undefined in the charset map to the rune of the same value.
+type singleByteCharset []rune
+
+func (c singleByteCharset) Bytes(b []byte) ([]byte, error) {
+	res := make([]byte, 0, len(b)*2)
+	for _, c0 := range b {
+		if c0 < 0x80 {
+			res = append(res, c0)
+			continue
+		}
+
+		r := rune(c0)
+		if i := int(c0) - 0x80; i < len(c) {
+			r = c[i]
+		}
+		res = append(res, string(r)...)
+	}
+
+	return res, nil
+}
+
+var (
+	// Windows1252 decodes Windows-1252, the superset of ISO-8859-1
+	// used by Windows in western Europe and America.
+	Windows1252 CharsetDecoder = singleByteCharset{
+		0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+		0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	}
+
+	// Windows1251 decodes Windows-1251, the Cyrillic code page of
+	// Windows.
+	Windows1251 CharsetDecoder = singleByteCharset{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x0098, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+		0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+		0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+		0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+		0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+		0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+		0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+		0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+		0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+		0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+		0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+	}
+
+	// KOI8R decodes KOI8-R, a Cyrillic charset used by Unix systems.
+	KOI8R CharsetDecoder = singleByteCharset{
+		0x2500, 0x2502, 0x250C, 0x2510, 0x2514, 0x2518, 0x251C, 0x2524,
+		0x252C, 0x2534, 0x253C, 0x2580, 0x2584, 0x2588, 0x258C, 0x2590,
+		0x2591, 0x2592, 0x2593, 0x2320, 0x25A0, 0x2219, 0x221A, 0x2248,
+		0x2264, 0x2265, 0x00A0, 0x2321, 0x00B0, 0x00B2, 0x00B7, 0x00F7,
+		0x2550, 0x2551, 0x2552, 0x0451, 0x2553, 0x2554, 0x2555, 0x2556,
+		0x2557, 0x2558, 0x2559, 0x255A, 0x255B, 0x255C, 0x255D, 0x255E,
+		0x255F, 0x2560, 0x2561, 0x0401, 0x2562, 0x2563, 0x2564, 0x2565,
+		0x2566, 0x2567, 0x2568, 0x2569, 0x256A, 0x256B, 0x256C, 0x00A9,
+		0x044E, 0x0430, 0x0431, 0x0446, 0x0434, 0x0435, 0x0444, 0x0433,
+		0x0445, 0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E,
+		0x043F, 0x044F, 0x0440, 0x0441, 0x0442, 0x0443, 0x0436, 0x0432,
+		0x044C, 0x044B, 0x0437, 0x0448, 0x044D, 0x0449, 0x0447, 0x044A,
+		0x042E, 0x0410, 0x0411, 0x0426, 0x0414, 0x0415, 0x0424, 0x0413,
+		0x0425, 0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E,
+		0x041F, 0x042F, 0x0420, 0x0421, 0x0422, 0x0423, 0x0416, 0x0412,
+		0x042C, 0x042B, 0x0417, 0x0428, 0x042D, 0x0429, 0x0427, 0x042A,
+	}
+)
+
+type charsetDetector []CharsetDecoder
+
+// DetectCharset returns a CharsetDecoder that heuristically chooses
+// between the candidates for every text it decodes. Text that is valid
+// UTF-8 is kept as is. Otherwise, the candidate producing the most
+// plausible text wins, with earlier candidates winning ties. If no
+// candidates are specified, Windows1252, Windows1251 and KOI8R are
+// used.
+//
+// Multi-byte charsets like Shift-JIS or GBK can be detected by passing
+// their decoders from golang.org/x/text.
+func DetectCharset(candidates ...CharsetDecoder) CharsetDecoder {
+	if len(candidates) == 0 {
+		candidates = []CharsetDecoder{Windows1252, Windows1251, KOI8R}
+	}
+
+	return charsetDetector(candidates)
+}
+
+func (d charsetDetector) Bytes(b []byte) ([]byte, error) {
+	if utf8pkg.Valid(b) {
+		return append([]byte(nil), b...), nil
+	}
+
+	var (
+		best      []byte
+		bestScore int
+		lastErr   error
+	)
+	for _, candidate := range d {
+		res, err := candidate.Bytes(b)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		score := plausibility(res)
+		if best == nil || score > bestScore {
+			best = res
+			bestScore = score
+		}
+	}
+
+	if best == nil {
+		return nil, lastErr
+	}
+
+	return best, nil
+}
+
+var scripts = []*unicode.RangeTable{
+	unicode.Latin,
+	unicode.Cyrillic,
+	unicode.Greek,
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Hangul,
+	unicode.Arabic,
+	unicode.Hebrew,
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for _, script := range scripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+
+	return nil
+}
+
+// plausibility rates how likely decoded text is to be the intended
+// text. Mis-decoded text usually contains replacement characters,
+// control characters and symbols, and switches between scripts or
+// letter case within words.
+func plausibility(text []byte) int {
+	var (
+		score        int
+		prevScript   *unicode.RangeTable
+		prevLower    bool
+		prevAccented bool
+	)
+
+	for _, r := range string(text) {
+		switch {
+		case r == utf8pkg.RuneError || unicode.IsControl(r) && r != 0:
+			score -= 10
+			prevScript = nil
+		case r < utf8pkg.RuneSelf && !unicode.IsLetter(r):
+			prevScript = nil
+			prevLower = false
+			prevAccented = false
+			continue
+		case unicode.IsLetter(r):
+			script := scriptOf(r)
+			if prevScript != nil && script != prevScript {
+				score -= 5
+			}
+			if prevScript != nil && prevLower && unicode.IsUpper(r) {
+				score -= 3
+			}
+			if r >= utf8pkg.RuneSelf {
+				score++
+				// Accented letters rarely follow each other in
+				// Latin script.
+				if script == unicode.Latin && prevAccented {
+					score -= 3
+				}
+			}
+			prevScript = script
+			prevLower = unicode.IsLower(r)
+			prevAccented = r >= utf8pkg.RuneSelf
+			continue
+		default:
+			score -= 2
+			prevScript = nil
+		}
+		prevLower = false
+		prevAccented = false
+	}
+
+	return score
+}
+
+// decodeLegacy decodes null separated ISO-8859-1 values with dec.
+func decodeLegacy(b []byte, dec CharsetDecoder) (string, error) {
+	b = bytes.TrimSuffix(b, nul)
+
+	var out []byte
+	for i, value := range bytes.Split(b, nul) {
+		if i > 0 {
+			out = append(out, 0)
+		}
+
+		res, err := dec.Bytes(value)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, res...)
+	}
+
+	return string(out), nil
+}
+
+// recodeFrame re-decodes the ISO-8859-1 text of a frame from the
+// bytes it was read from, using dec, and reports whether the text
+// changed. Frames that contain no such text, weren't read from a tag
+// or have been modified since are returned unchanged.
+func recodeFrame(frame Frame, dec CharsetDecoder) (Frame, bool, error) {
+	orig := frame
+	raw := sourceBytes(frame)
+	if len(raw) <= frameLength || raw[frameLength] != byte(iso88591) {
+		return frame, false, nil
+	}
+	body := raw[frameLength+1:]
+
+	var err error
+	switch f := frame.(type) {
+	case TextInformationFrame:
+		f.Text, err = decodeLegacy(body, dec)
+		frame = f
+	case UserTextInformationFrame:
+		parts := splitNullN(body, iso88591, 2)
+		if len(parts) != 2 {
+			return frame, false, nil
+		}
+		f.Description, err = decodeLegacy(parts[0], dec)
+		if err == nil {
+			f.Text, err = decodeLegacy(parts[1], dec)
+		}
+		frame = f
+	case UserDefinedURLLinkFrame:
+		parts := splitNullN(body, iso88591, 2)
+		f.Description, err = decodeLegacy(parts[0], dec)
+		frame = f
+	case CommentFrame:
+		if len(body) < 3 {
+			return frame, false, nil
+		}
+		parts := splitNullN(body[3:], iso88591, 2)
+		if len(parts) != 2 {
+			return frame, false, nil
+		}
+		f.Description, err = decodeLegacy(parts[0], dec)
+		if err == nil {
+			f.Text, err = decodeLegacy(parts[1], dec)
+		}
+		frame = f
+	case UnsynchronisedLyricsFrame:
+		if len(body) < 3 {
+			return frame, false, nil
+		}
+		parts := splitNullN(body[3:], iso88591, 2)
+		if len(parts) != 2 {
+			return frame, false, nil
+		}
+		f.Description, err = decodeLegacy(parts[0], dec)
+		if err == nil {
+			f.Lyrics, err = decodeLegacy(parts[1], dec)
+		}
+		frame = f
+	case PictureFrame:
+		parts := splitNullN(body, iso88591, 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			return frame, false, nil
+		}
+		parts = splitNullN(parts[1][1:], iso88591, 2)
+		f.Description, err = decodeLegacy(parts[0], dec)
+		frame = f
+	default:
+		return frame, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return frame, !sameFrame(frame, orig), nil
+}
+
+// RepairCharset re-decodes all text that frames claim to store as
+// ISO-8859-1 using dec, for example one returned by DetectCharset. It
+// only affects frames that haven't been modified since they were
+// read, and returns how many frames were changed. Frames that dec
+// fails to decode are left untouched.
+func (t *Tag) RepairCharset(dec CharsetDecoder) int {
+	n := 0
+	for id, frames := range t.Frames {
+		for i, frame := range frames {
+			res, changed, err := recodeFrame(frame, dec)
+			if err != nil {
+				t.logger().Warn("cannot decode frame with legacy charset", "frame", id, "action", "left untouched", "error", err)
+				continue
+			}
+			if changed {
+				frames[i] = res
+				n++
+			}
+		}
+	}
+
+	return n
+}
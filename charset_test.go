@@ -0,0 +1,77 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSingleByteCharsets(t *testing.T) {
+	tests := []struct {
+		dec CharsetDecoder
+		in  string
+		out string
+	}{
+		{Windows1252, "\x80 caf\xE9", "€ café"},
+		{Windows1251, "\xCA\xE8\xED\xEE", "Кино"},
+		{KOI8R, "\xE7\xD2\xD5\xD0\xD0\xC1\x20\xCB\xD2\xCF\xD7\xC9", "Группа крови"},
+	}
+
+	for _, test := range tests {
+		res, err := test.dec.Bytes([]byte(test.in))
+		if err != nil || string(res) != test.out {
+			t.Errorf("Decoded %q to %q, %v, expected %q", test.in, res, err, test.out)
+		}
+	}
+}
+
+func TestDetectCharset(t *testing.T) {
+	dec := DetectCharset()
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"plain ASCII", "plain ASCII"},
+		{"UTF-8 ü", "UTF-8 ü"},
+		{"\xCA\xE8\xED\xEE", "Кино"},
+		{"\xE7\xD2\xD5\xD0\xD0\xC1\x20\xCB\xD2\xCF\xD7\xC9", "Группа крови"},
+		{"\x43\x61\x66\xE9\x20\x4E\x6F\xEB\x6C", "Café Noël"},
+	}
+
+	for _, test := range tests {
+		res, err := dec.Bytes([]byte(test.in))
+		if err != nil || string(res) != test.out {
+			t.Errorf("Decoded %q to %q, %v, expected %q", test.in, res, err, test.out)
+		}
+	}
+}
+
+func TestLegacyCharset(t *testing.T) {
+	var tag []byte
+	for _, frame := range [][]byte{
+		rawFrame("TIT2", 0, append([]byte{0}, "\xCA\xE8\xED\xEE"...)),
+		rawFrame("COMM", 0, append([]byte("\x00rus\x00"), "\xCA\xE8\xED\xEE"...)),
+	} {
+		tag = append(tag, frame...)
+	}
+	data := append(generateHeader(len(tag), 0), tag...)
+
+	parsed, err := ParseCharset(bytes.NewReader(data), Windows1251)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title, comment := parsed.Title(), parsed.Frames["COMM"][0].Value(); title != "Кино" || comment != "Кино" {
+		t.Fatalf("Got %q and %q, expected %q", title, comment, "Кино")
+	}
+
+	parsed, err = Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.SetTitle("Title")
+	if n := parsed.RepairCharset(DetectCharset()); n != 1 {
+		t.Fatalf("Repaired %d frames, expected 1", n)
+	}
+	if title, comment := parsed.Title(), parsed.Frames["COMM"][0].Value(); title != "Title" || comment != "Кино" {
+		t.Fatalf("Got %q and %q after repairing", title, comment)
+	}
+}
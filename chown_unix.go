@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+
+package id3
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike gives f the owner and group described by fi.
+func chownLike(f *os.File, fi os.FileInfo) error {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return f.Chown(int(stat.Uid), int(stat.Gid))
+}
@@ -0,0 +1,164 @@
+package id3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var ErrNoFrameCodec = errors.New("id3: no codec registered for frame")
+
+// A FrameCodec converts between the body of a frame, as stored in the
+// tag, and a value of the codec's choosing. It allows reading and
+// writing frames that aren't supported by this package, like
+// proprietary extensions.
+type FrameCodec interface {
+	// Decode parses the body of a frame, excluding its header.
+	Decode(data []byte) (interface{}, error)
+	// Encode returns the body of a frame for a value previously
+	// returned by Decode or set by the user.
+	Encode(value interface{}) ([]byte, error)
+}
+
+var (
+	frameCodecsMu sync.RWMutex
+	frameCodecs   = make(map[FrameType]FrameCodec)
+)
+
+// builtinFrames are the frames, besides text and URL frames, that the
+// package decodes itself.
+var builtinFrames = map[FrameType]bool{
+	"APIC": true,
+	"ASPI": true,
+	"COMM": true,
+	"MCDI": true,
+	"MLLT": true,
+	"POSS": true,
+	"PRIV": true,
+	"SEEK": true,
+	"UFID": true,
+	"USLT": true,
+}
+
+// isBuiltinFrame reports whether the package decodes frames with the
+// given ID itself.
+func isBuiltinFrame(id FrameType) bool {
+	return len(id) > 0 && (id[0] == 'T' || id[0] == 'W') || builtinFrames[id]
+}
+
+// RegisterFrameCodec registers the codec that will be used to read and
+// write frames with the given ID. Frames handled by a codec will be of
+// type CustomFrame. Registering a nil codec removes the registration.
+//
+// Frames the codec fails to decode are read as UnsupportedFrame,
+// unless parsing is strict, in which case Parse returns the codec's
+// error.
+//
+// Frames the package supports itself, which includes all text and URL
+// frames, cannot be handled by codecs; RegisterFrameCodec panics for
+// their IDs.
+func RegisterFrameCodec(id FrameType, codec FrameCodec) {
+	if isBuiltinFrame(id) {
+		panic(fmt.Sprintf("id3: cannot register codec for built-in frame %s", id))
+	}
+
+	frameCodecsMu.Lock()
+	defer frameCodecsMu.Unlock()
+
+	if codec == nil {
+		delete(frameCodecs, id)
+		return
+	}
+	frameCodecs[id] = codec
+}
+
+func lookupFrameCodec(id FrameType) (FrameCodec, bool) {
+	frameCodecsMu.RLock()
+	defer frameCodecsMu.RUnlock()
+
+	codec, ok := frameCodecs[id]
+	return codec, ok
+}
+
+// CustomFrame is a frame that has been decoded by a registered
+// FrameCodec. Data holds the value returned by the codec.
+type CustomFrame struct {
+	FrameHeader
+	Data interface{}
+}
+
+// NewCustomFrame returns a frame with the given ID and value. A codec
+// for the ID has to be registered before the frame can be encoded.
+func NewCustomFrame(id FrameType, data interface{}) CustomFrame {
+	return CustomFrame{FrameHeader: FrameHeader{id: id}, Data: data}
+}
+
+// Value returns the string representation of Data if it implements
+// fmt.Stringer, and an empty string otherwise.
+func (f CustomFrame) Value() string {
+	if s, ok := f.Data.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return ""
+}
+
+func (f CustomFrame) body() ([]byte, error) {
+	codec, ok := lookupFrameCodec(f.ID())
+	if !ok {
+		return nil, ErrNoFrameCodec
+	}
+
+	return codec.Encode(f.Data)
+}
+
+func (f CustomFrame) Size() int {
+	body, err := f.body()
+	if err != nil {
+		// Encode will fail with the same error
+		return frameLength
+	}
+
+	return frameLength + len(body)
+}
+
+func (f CustomFrame) Encode(w io.Writer) error {
+	body, err := f.body()
+	if err != nil {
+		return err
+	}
+
+	return writeMany(w,
+		f.FrameHeader.Serialize(len(body)),
+		body,
+	)
+}
+
+// A codecError is returned when a codec fails to decode a frame.
+// frame holds the undecoded frame, which Parse keeps instead unless
+// it is strict.
+type codecError struct {
+	err   error
+	frame UnsupportedFrame
+}
+
+func (e codecError) Error() string {
+	return e.err.Error()
+}
+
+func readCustomFrame(r io.Reader, header FrameHeader, frameSize int, codec FrameCodec) (Frame, error) {
+	frame := CustomFrame{FrameHeader: header}
+	data := make([]byte, frameSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.Data, err = codec.Decode(data)
+	if err != nil {
+		return nil, codecError{err, UnsupportedFrame{FrameHeader: header, Data: data}}
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,121 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+type grouping string
+
+func (g grouping) String() string {
+	return string(g)
+}
+
+type groupingCodec struct{}
+
+func (groupingCodec) Decode(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, ErrMalformedFrame
+	}
+
+	return grouping(Encoding(data[0]).toUTF8(data[1:])), nil
+}
+
+func (groupingCodec) Encode(value interface{}) ([]byte, error) {
+	return append(utf8byte, value.(grouping)...), nil
+}
+
+func TestFrameCodec(t *testing.T) {
+	RegisterFrameCodec("GRP1", groupingCodec{})
+	defer RegisterFrameCodec("GRP1", nil)
+
+	tag := NewTag()
+	tag.Frames["GRP1"] = []Frame{NewCustomFrame("GRP1", grouping("Grouping"))}
+
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := parsed.Frames["GRP1"]
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 GRP1 frame, got %d", len(frames))
+	}
+	frame, ok := frames[0].(CustomFrame)
+	if !ok {
+		t.Fatalf("Expected CustomFrame, got %T", frames[0])
+	}
+	if frame.Data != grouping("Grouping") || frame.Value() != "Grouping" {
+		t.Fatalf("Unexpected value %#v", frame.Data)
+	}
+	if parsed.Header.Size != tag.framesSize()+Padding {
+		t.Fatalf("Tag size is %d, expected %d", parsed.Header.Size, tag.framesSize()+Padding)
+	}
+}
+
+func TestFrameCodecUnregistered(t *testing.T) {
+	frame := NewCustomFrame("GRP1", grouping("Grouping"))
+	if err := frame.Encode(new(bytes.Buffer)); err != ErrNoFrameCodec {
+		t.Fatalf("Expected ErrNoFrameCodec, got %v", err)
+	}
+}
+
+func TestFrameCodecDecodeError(t *testing.T) {
+	RegisterFrameCodec("GRP1", groupingCodec{})
+	defer RegisterFrameCodec("GRP1", nil)
+
+	body := rawFrame("GRP1", 0, nil)
+	data := append(generateHeader(len(body), 0), body...)
+
+	logger := new(testLogger)
+	opts := DefaultOptions()
+	opts.Logger = logger
+	tag, err := ParseWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := tag.Frames["GRP1"]
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 GRP1 frame, got %d", len(frames))
+	}
+	if frame, ok := frames[0].(UnsupportedFrame); !ok || len(frame.Data) != 0 {
+		t.Fatalf("Expected empty UnsupportedFrame, got %#v", frames[0])
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("Expected 1 warning, logged %q", logger.lines)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := frames[0].Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), body) {
+		t.Fatalf("Frame encoded as %v, expected %v", buf.Bytes(), body)
+	}
+
+	opts.Strict = true
+	if _, err := ParseWithOptions(bytes.NewReader(data), opts); err != ErrMalformedFrame {
+		t.Fatalf("Expected ErrMalformedFrame, got %v", err)
+	}
+}
+
+func TestFrameCodecBuiltin(t *testing.T) {
+	for _, id := range []FrameType{"COMM", "TXXX", "TIT2", "WOAR"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Registering codec for %s didn't panic", id)
+				}
+			}()
+			RegisterFrameCodec(id, groupingCodec{})
+		}()
+		if _, ok := lookupFrameCodec(id); ok {
+			t.Errorf("Codec for %s was registered", id)
+		}
+	}
+}
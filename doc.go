@@ -36,16 +36,73 @@ and immediately save it, it will now be a file with valid v2.4 tags.
 
 The upgrade process makes the following changes to the tags:
 
-  - TYER, TDAT and TIME get replaced by TDRC
-  - TORY gets replaced by TDOR
-  - XDOR gets replaced by TDOR
+  - TYER, TDAT and TIME get replaced by TDRC, as precise as they allow,
+    if TYER contains a year
+  - TORY and XDOR get replaced by TDOR
+  - TRDA gets replaced by TDRL if it contains a recognizable date
   - The slash as a separator for multiple values gets replaced by null bytes
 
-One special case is the TRDA frame because there is no way to
-automatically convert it to v2.4. The upgrade process will not
-directly delete the frame, so that you can manually upgrade it if
-desired, but it won't be written back to the file. The frame is rarely
-used and insignificant, so it's not a big loss.
+All changes are listed in Tag.Upgrades.
+
+TRDA frames without a recognizable date will not be deleted, so that
+you can manually upgrade them if desired, but they won't be written
+back to the file. The frame is rarely used and insignificant, so it's
+not a big loss. Likewise, TYER, TDAT and TIME are kept as they are if
+TYER contains no year.
+
+
+Appended tags
+
+Version 2.4 allows placing the tag at the end of the file, marked by
+a footer, optionally followed by an ID3v1 tag. Open will use such a
+tag if the file doesn't start with one, and merge its frames
+otherwise. Setting File.Append makes Save write the tag to the end of
+the file, which avoids rewriting the audio data when the file has no
+tag at its beginning.
+
+ParseAt and ParseReadSeeker read tags like Open from files that
+aren't on disk, like objects in a store, and return their Layout: the
+byte ranges of the tags and the audio data.
+
+
+Saving
+
+If the new tag fits into the space of the existing one, Save
+overwrites it in place after encoding the complete tag. Otherwise,
+the file is written to a temporary file in the same directory, which
+then replaces the original, so that a failed save or a crash cannot
+destroy the file. Symbolic links are resolved, so that their target is
+replaced. Options.Preserve selects which attributes of the original
+file the new one keeps. If no temporary file can be created, Save fails, unless
+Options.RewriteInPlace allows overwriting the file directly.
+
+A new tag is followed by padding, so that later saves can grow it in
+place. Tag.Padding or File.SaveWithPadding select a PaddingPolicy,
+which can add a fixed or proportional amount, round the tag to a block
+size, and limit padding to a maximum. Saving in place keeps the
+existing padding, so files are never rewritten just to shrink it.
+When the file is rewritten anyway, existing padding is kept as well,
+unless PaddingPolicy.Shrink allows reducing it.
+
+OpenFS opens files in an fs.FS, like an archive or an in-memory file
+system. Files in a WriteFS, which can open files for writing and
+rename them, can be saved as well; DirFS provides one for a directory
+on disk.
+
+
+Options
+
+Package-level variables like Padding and Logging only serve as
+defaults, read whenever a function without options is called. Newer
+settings, like Options.Duplicates and Options.Limits, can only be
+changed through options. To use different settings concurrently, pass
+Options to ParseWithOptions, OpenWithOptions or File.SaveWithOptions,
+starting from DefaultOptions. Options also allow strict parsing and saving,
+which fail instead of working around invalid data.
+
+Diagnostic messages go to Options.Logger, which a *slog.Logger
+satisfies. Messages carry the file path, frame ID and the action
+taken as structured attributes.
 
 
 Accessing and manipulating frames
@@ -68,17 +125,85 @@ case of user text frames ("TXXX") where it expects a format of the
 kind "TXXX:The frame description" to address a specific user text
 frame.
 
+Timestamps, like the recording time, may omit trailing components:
+"2009" denotes a year, "2009-11-10" a day. The Timestamp type records
+this precision, so that values are written back exactly as read.
+
+The specification limits how many frames with the same ID may exist:
+only one of each text frame, one TXXX, COMM or USLT frame per
+description (and language), one UFID or PRIV frame per owner, and so
+on. The setters and Tag.SetFrame replace frames accordingly, and Parse
+resolves duplicates as configured by Options.Duplicates.
+
+
+Lazy parsing
+
+Options.Lazy defers decoding large binary frames, like pictures, which
+are stored as LazyFrames until Decode or Tag.DecodeFrames is called.
+Options.Filter skips unwanted frames entirely. Both avoid reading
+cover art when only a few text frames are needed.
+
+For even more control, a Reader returns the frames of a tag one at a
+time, with their offset and size, and only reads or decodes a frame's
+body on request.
+
+
+Frame order
+
+Frames are written in a deterministic order. Frames that were read
+from a file keep their original order, while new frames are inserted
+according to Tag.Order, which defaults to DefaultFrameOrder: title,
+artist and album first, attached pictures last, and all other frames
+sorted by their ID.
+
+
+Lossless mode
+
+Normally all frames get re-encoded when saving. If Tag.Lossless is
+set, frames that haven't been modified since they were read are
+written using their original bytes, preserving their encoding, flags
+and any data the library doesn't interpret. No TDTG frame will be
+added either. This only applies to frames read from v2.4 tags, as
+older tags get upgraded.
+
+Frames count as modified when their content or flags differ from what
+was decoded from the original bytes, no matter whether they were
+changed by setters or by assigning their fields directly.
+
+
+Frame flags
+
+Flags of frames are preserved when modifying frames with the setter
+methods. Frames flagged as read-only will not be modified by setters.
+As the specification asks for, the alteration flags only apply to
+unsupported frames: saving a tag discards those flagged to be
+discarded when the tag is altered, and Tag.RemoveFileAlterationFrames
+discards those flagged to be discarded when the audio data is
+altered.
+
 
 Encodings
 
-While ID3v2 allows a variety of encodings (ISO-8859-1, UTF-16 and in
-v2.4 also UTF-8), this library only supports writing UTF-8. When
-reading frames with different encodings, they will be converted to
-UTF-8.
+ID3v2 allows a variety of encodings (ISO-8859-1, UTF-16 and in v2.4
+also UTF-8). When reading frames, text will be converted to UTF-8,
+which is the encoding assumed by most of the Go standard library.
+
+By default, frames are written as UTF-8 as well. Because some
+software and hardware cannot display UTF-8 in ID3 tags, Tag.Encoding
+can select a different policy: AlwaysUTF16, or PreferISO88591, which
+uses ISO-8859-1 for frames that can be represented in it and UTF-16
+for all others.
+
+Some fields, like URLs and MIME types, always have to be written as
+ISO-8859-1. Text that cannot be represented in it causes an
+UnrepresentableError, unless Tag.ISO88591Replacement specifies a rune
+to substitute.
 
-The rationale behind this is that UTF-8 is the encoding assumed by
-most of the Go standard library, and that the other encodings have no
-realistic benefits over UTF-8.
+Many programs wrote text in legacy charsets like Windows-1251 or
+Shift-JIS into frames that claim to be ISO-8859-1. ParseCharset decodes
+such text with a CharsetDecoder, and Tag.RepairCharset re-decodes
+frames of an already parsed tag from their original bytes.
+DetectCharset returns a decoder that guesses the charset.
 
 
 Behaviour when encountering invalid data
@@ -100,6 +225,20 @@ it cannot be ensured that bad things won't happen.
 
 In the second case only that specific frame will be dropped.
 
+Tag.Validate reports tags that violate the specification, including
+the ID3v2.4 tag restrictions set in Tag.Restrictions, and
+Tag.Sanitize fixes them. Restrictions aren't read from files, because
+tags with an extended header are rejected, so they have to be set by
+the caller.
+
+Sizes are taken from the tag, so hostile input could make the parser
+allocate large amounts of memory. Options.Limits restrict the tag
+size, frame size and number of frames; exceeding them results in a
+LimitError before anything is allocated. DefaultOptions limits tags
+to 64 MB, frames to 32 MB and the number of frames to 4096. Frame
+bodies are read in chunks, so that the memory used is bounded by the
+size of the input, too.
+
 
 Unsupported frames
 
@@ -109,5 +248,16 @@ know how to handle them, you can write your own function to parse and
 modify the content. All unsupported frames will be of type
 UnsupportedFrame.
 
+Alternatively, a FrameCodec can be registered for a frame ID with
+RegisterFrameCodec. Frames with that ID will then be decoded by the
+codec, be of type CustomFrame, and be encoded by the codec when saving.
+Frames the codec fails to decode are kept as UnsupportedFrame and a
+warning is logged, unless parsing is strict. Codecs cannot replace the
+package's support for frames it knows.
+
+Frames can also be created from scratch by implementing the Frame
+interface, embedding a FrameHeader returned by NewFrameHeader, and
+storing them in Tag.Frames.
+
 */
 package id3 // import "honnef.co/go/id3"
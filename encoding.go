@@ -2,6 +2,7 @@ package id3
 
 import (
 	"fmt"
+	"strings"
 	utf16pkg "unicode/utf16"
 )
 
@@ -20,6 +21,43 @@ var (
 
 type Encoding byte
 
+// valid reports whether the encoding is one defined by ID3v2.4.
+func (e Encoding) valid() bool {
+	return e <= utf8
+}
+
+// An EncodingPolicy determines which text encoding is used when
+// writing frames.
+type EncodingPolicy byte
+
+const (
+	// AlwaysUTF8 writes all text as UTF-8. This is the default.
+	AlwaysUTF8 EncodingPolicy = iota
+	// AlwaysUTF16 writes all text as UTF-16 with a byte order mark.
+	AlwaysUTF16
+	// PreferISO88591 writes text as ISO-8859-1 if it can be
+	// represented in it, and as UTF-16 otherwise.
+	PreferISO88591
+)
+
+// encoding returns the encoding the policy selects for a frame
+// containing the given texts.
+func (p EncodingPolicy) encoding(texts ...string) Encoding {
+	switch p {
+	case AlwaysUTF16:
+		return utf16bom
+	case PreferISO88591:
+		for _, text := range texts {
+			if !isISO88591(text) {
+				return utf16bom
+			}
+		}
+		return iso88591
+	default:
+		return utf8
+	}
+}
+
 func (e Encoding) String() string {
 	switch e {
 	case iso88591:
@@ -56,12 +94,39 @@ func (e Encoding) toUTF8(b []byte) []byte {
 	return ret
 }
 
-func (e Encoding) toISO88591(b []byte) []byte {
-	if e != utf8 {
-		panic("Conversion to ISO-8859-1 is only implemented for UTF-8")
+// fromUTF8 converts UTF-8 text to the encoding, without a terminator.
+// In UTF-16, every null separated value starts with its own byte order
+// mark.
+func (e Encoding) fromUTF8(s string) []byte {
+	switch e {
+	case utf16bom, utf16be:
+		var out []byte
+		for i, value := range strings.Split(s, "\x00") {
+			if i > 0 {
+				out = append(out, utf16nul...)
+			}
+			out = append(out, utf8ToUTF16(value, e == utf16bom)...)
+		}
+		return out
+	case iso88591:
+		// Policies only select ISO-8859-1 for representable text.
+		b, _ := utf8ToISO88591([]byte(s), '?')
+		return b
+	default:
+		return []byte(s)
+	}
+}
+
+// lastFromUTF8 converts the last text of a frame like fromUTF8. Text
+// ending in an empty value gets a terminator, which readers strip, so
+// that the empty value isn't lost.
+func (e Encoding) lastFromUTF8(s string) []byte {
+	b := e.fromUTF8(s)
+	if strings.HasSuffix(s, "\x00") {
+		b = append(b, e.terminator()...)
 	}
 
-	return utf8ToISO88591(b)
+	return b
 }
 
 func (e Encoding) terminator() []byte {
@@ -73,77 +138,111 @@ func (e Encoding) terminator() []byte {
 	}
 }
 
+// utf16ToUTF8 converts UTF-16 text to UTF-8. Null separated values
+// may each start with their own byte order mark.
 func utf16ToUTF8(input []byte) []byte {
+	var out []byte
 	// ID3v2 allows UTF-16 in two ways: With a BOM or as Big Endian.
 	// So if we have no Little Endian BOM, it has to be Big Endian
 	// either way.
 	bigEndian := true
-	if input[0] == 0xFF && input[1] == 0xFE {
-		bigEndian = false
-		input = input[2:]
-	} else if input[0] == 0xFE && input[1] == 0xFF {
-		input = input[2:]
-	}
+	for i, value := range splitNullN(input, utf16be, -1) {
+		if i > 0 {
+			out = append(out, 0)
+		}
 
-	uint16s := make([]uint16, len(input)/2)
+		if len(value) >= 2 {
+			if value[0] == 0xFF && value[1] == 0xFE {
+				bigEndian = false
+				value = value[2:]
+			} else if value[0] == 0xFE && value[1] == 0xFF {
+				bigEndian = true
+				value = value[2:]
+			}
+		}
 
-	i := 0
-	for j := 0; j < len(input); j += 2 {
-		if bigEndian {
-			uint16s[i] = uint16(input[j])<<8 | uint16(input[j+1])
-		} else {
-			uint16s[i] = uint16(input[j]) | uint16(input[j+1])<<8
+		uint16s := make([]uint16, len(value)/2)
+		for j := range uint16s {
+			if bigEndian {
+				uint16s[j] = uint16(value[2*j])<<8 | uint16(value[2*j+1])
+			} else {
+				uint16s[j] = uint16(value[2*j]) | uint16(value[2*j+1])<<8
+			}
 		}
 
-		i++
+		out = append(out, string(utf16pkg.Decode(uint16s))...)
 	}
 
-	return []byte(string(utf16pkg.Decode(uint16s)))
+	return out
 }
 
-func utf8ToISO88591(input []byte) []byte {
-	res := make([]byte, len(input))
-	i := 0
+// utf8ToUTF16 converts UTF-8 text to big endian UTF-16, optionally
+// preceded by a byte order mark.
+func utf8ToUTF16(s string, bom bool) []byte {
+	units := utf16pkg.Encode([]rune(s))
+	out := make([]byte, 0, 2*len(units)+2)
+	if bom {
+		out = append(out, 0xFE, 0xFF)
+	}
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+
+	return out
+}
 
-	for j := 0; j < len(input); j++ {
-		if input[j] <= 128 {
-			res[i] = input[j]
-		} else {
-			if input[j] == 195 {
-				res[i] = input[j+1] + 64
-			} else {
-				res[i] = input[j+1]
+// isISO88591 reports whether s can be represented in ISO-8859-1.
+func isISO88591(s string) bool {
+	for _, r := range s {
+		if r > 0xFF {
+			return false
+		}
+	}
+
+	return true
+}
+
+// An UnrepresentableError is returned when text that has to be stored
+// as ISO-8859-1 contains a rune outside of it.
+type UnrepresentableError struct {
+	Rune rune
+}
+
+func (e UnrepresentableError) Error() string {
+	return fmt.Sprintf("id3: %q (%U) cannot be represented in ISO-8859-1", e.Rune, e.Rune)
+}
+
+// utf8ToISO88591 converts UTF-8 text to ISO-8859-1. Runes outside of
+// ISO-8859-1, including invalid UTF-8, are replaced with replacement.
+// If replacement is 0 or isn't in ISO-8859-1 itself, they cause an
+// UnrepresentableError instead.
+func utf8ToISO88591(input []byte, replacement rune) ([]byte, error) {
+	res := make([]byte, 0, len(input))
+	for _, r := range string(input) {
+		if r > 0xFF {
+			if replacement <= 0 || replacement > 0xFF {
+				return nil, UnrepresentableError{r}
 			}
-			j++
+			r = replacement
 		}
-		i++
+		res = append(res, byte(r))
 	}
 
-	return res[:i]
+	return res, nil
 }
 
 func iso88591ToUTF8(input []byte) []byte {
 	// - ISO-8859-1 bytes match Unicode code points
 	// - All runes <128 correspond to ASCII, same as in UTF-8
-	// - All runes >128 in ISO-8859-1 encode as 2 bytes in UTF-8
-	res := make([]byte, len(input)*2)
-
-	var j int
+	// - All runes >=128 in ISO-8859-1 encode as 2 bytes in UTF-8
+	res := make([]byte, 0, len(input)*2)
 	for _, b := range input {
-		if b <= 128 {
-			res[j] = b
-			j++
+		if b < 0x80 {
+			res = append(res, b)
 		} else {
-			if b >= 192 {
-				res[j] = 195
-				res[j+1] = b - 64
-			} else {
-				res[j] = 194
-				res[j+1] = b
-			}
-			j += 2
+			res = append(res, 0xC0|b>>6, 0x80|b&0x3F)
 		}
 	}
 
-	return res[:j]
+	return res
 }
@@ -0,0 +1,51 @@
+package id3_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"honnef.co/go/id3"
+)
+
+// playCount is a Frame implemented outside of the package.
+type playCount struct {
+	id3.FrameHeader
+	Count uint32
+}
+
+func (f playCount) Value() string {
+	return ""
+}
+
+func (f playCount) Size() int {
+	return 10 + 4
+}
+
+func (f playCount) Encode(w io.Writer) error {
+	_, err := w.Write(append(f.Serialize(4), byte(f.Count>>24), byte(f.Count>>16), byte(f.Count>>8), byte(f.Count)))
+	return err
+}
+
+func TestExternalFrame(t *testing.T) {
+	tag := id3.NewTag()
+	tag.Frames["PCNT"] = []id3.Frame{playCount{FrameHeader: id3.NewFrameHeader("PCNT", 0), Count: 258}}
+
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := id3.Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, ok := parsed.Frames["PCNT"][0].(id3.UnsupportedFrame)
+	if !ok {
+		t.Fatalf("Expected UnsupportedFrame, got %T", parsed.Frames["PCNT"][0])
+	}
+	if !bytes.Equal(frame.Data, []byte{0, 0, 1, 2}) {
+		t.Fatalf("Unexpected frame body %v", frame.Data)
+	}
+}
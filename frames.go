@@ -1,9 +1,12 @@
 package id3
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"io"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 var FrameNames = map[FrameType]string{
@@ -131,16 +134,40 @@ var PictureTypes = []string{
 	"Publisher/Studio logotype",
 }
 
+// TimestampFormat specifies the unit of time stamps in frames like
+// POSS.
+type TimestampFormat byte
+
+const (
+	TimestampMPEGFrames   TimestampFormat = 1
+	TimestampMilliseconds TimestampFormat = 2
+)
+
+// FrameHeader holds the ID and flags of a frame. It is meant to be
+// embedded in types implementing Frame.
 type FrameHeader struct {
 	id    FrameType
 	flags FrameFlags
+	raw   []byte // The frame's original bytes, if read from a tag
+	// The frame as decoded from raw, to detect modifications
+	orig *snapshot
+	// Whether the frame was read from a tag older than v2.4, whose
+	// raw bytes cannot be written verbatim.
+	legacy bool
 }
 
+// Frame is implemented by all frames. Types outside of this package
+// can implement it, too, by embedding a FrameHeader created with
+// NewFrameHeader, and be stored in Tag.Frames.
 type Frame interface {
 	ID() FrameType
 	Value() string
+	// Encode writes the frame, including its header, to w. It must
+	// write exactly Size() bytes.
 	Encode(w io.Writer) error
-	size() int // TODO export?
+	// Size returns the size of the encoded frame in bytes,
+	// including the 10 byte header.
+	Size() int
 }
 
 type TextInformationFrame struct {
@@ -204,16 +231,178 @@ type UnsynchronisedLyricsFrame struct {
 	Lyrics      string
 }
 
+// MPEGLocationLookupTableFrame describes the MLLT frame. It stores
+// reference points into the MPEG audio stream, expressed as
+// deviations from a fixed distance in bytes and milliseconds.
+type MPEGLocationLookupTableFrame struct {
+	FrameHeader
+	FramesBetweenReference       int
+	BytesBetweenReference        int
+	MillisecondsBetweenReference int
+	BitsForBytesDeviation        byte
+	BitsForMillisecondsDeviation byte
+	References                   []MPEGLocationReference
+}
+
+// MPEGLocationReference is a single entry of an MLLT frame.
+type MPEGLocationReference struct {
+	BytesDeviation        uint32
+	MillisecondsDeviation uint32
+}
+
+// AudioSeekPointIndexFrame describes the ASPI frame. The index points
+// are fractions of DataLength, evenly spaced in time. BitsPerPoint is
+// either 8 or 16.
+type AudioSeekPointIndexFrame struct {
+	FrameHeader
+	DataStart    int64 // Offset of the indexed data from the beginning of the file
+	DataLength   int64
+	BitsPerPoint byte
+	Points       []uint16
+}
+
+// PositionSynchronisationFrame describes the POSS frame. Position is
+// the point in the audio, in units of TimestampFormat, at which the
+// listener starts receiving the stream.
+type PositionSynchronisationFrame struct {
+	FrameHeader
+	TimestampFormat TimestampFormat
+	Position        uint64
+}
+
+// SeekFrame describes the SEEK frame. MinimumOffset is the distance in
+// bytes between the end of this tag and the beginning of the next
+// one.
+type SeekFrame struct {
+	FrameHeader
+	MinimumOffset int64
+}
+
 type UnsupportedFrame struct {
 	FrameHeader
 	Data []byte
 }
 
+// NewFrameHeader returns a header for a frame with the given ID and
+// flags.
+func NewFrameHeader(id FrameType, flags FrameFlags) FrameHeader {
+	return FrameHeader{id: id, flags: flags}
+}
+
 func (f FrameHeader) ID() FrameType {
 	return f.id
 }
 
-func (f FrameHeader) serialize(size int) []byte {
+func (f FrameHeader) Flags() FrameFlags {
+	return f.flags
+}
+
+func (f FrameHeader) isLegacy() bool {
+	return f.legacy
+}
+
+// sourceBytes returns the bytes a frame was read from, or nil if it
+// wasn't read from a tag or has been modified since.
+func sourceBytes(frame Frame) []byte {
+	f, ok := frame.(interface {
+		source() ([]byte, *snapshot)
+	})
+	if !ok {
+		return nil
+	}
+
+	raw, orig := f.source()
+	if raw == nil || orig == nil || !orig.matches(frame) {
+		return nil
+	}
+	return raw
+}
+
+func (f FrameHeader) source() ([]byte, *snapshot) {
+	return f.raw, f.orig
+}
+
+// A snapshot holds a frame as it was decoded from the bytes it was
+// read from. It is shared by all copies of the frame, so that a copy
+// that was modified and stored in a tag can be told apart from one
+// that wasn't.
+type snapshot struct {
+	frame Frame
+	once  sync.Once
+	sum   [sha256.Size]byte
+	ok    bool
+}
+
+// matches reports whether frame has the same content as the frame
+// that was originally decoded.
+func (s *snapshot) matches(frame Frame) bool {
+	s.once.Do(func() { s.sum, s.ok = fingerprint(s.frame) })
+	sum, ok := fingerprint(frame)
+	return s.ok && ok && sum == s.sum
+}
+
+// fingerprint returns a hash of a frame's encoding, including its
+// flags, which is equal for frames with the same content. Text is
+// encoded as UTF-8, which can represent any text.
+func fingerprint(frame Frame) (sum [sha256.Size]byte, ok bool) {
+	h := sha256.New()
+	e := encoder{policy: AlwaysUTF8}
+
+	var err error
+	if f, isText := frame.(TextInformationFrame); isText && f.obsolete() {
+		// Obsolete frames aren't encoded, but still have content.
+		body, bodyErr := f.body(e)
+		err = writeFrame(h, f.FrameHeader, body, bodyErr)
+	} else {
+		err = encodeFrame(h, frame, e)
+	}
+	if err != nil {
+		return sum, false
+	}
+
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
+
+// sameFrame reports whether two frames have the same content.
+func sameFrame(a, b Frame) bool {
+	sumA, okA := fingerprint(a)
+	sumB, okB := fingerprint(b)
+	return okA && okB && sumA == sumB
+}
+
+func (f *FrameHeader) SetFlags(flags FrameFlags) {
+	f.flags = flags
+}
+
+func (f *FrameHeader) SetPreserveTagAlteration(preserve bool) {
+	f.SetFlags(f.flags.set(flagDiscardOnTagAlteration, !preserve))
+}
+
+func (f *FrameHeader) SetPreserveFileAlteration(preserve bool) {
+	f.SetFlags(f.flags.set(flagDiscardOnFileAlteration, !preserve))
+}
+
+func (f *FrameHeader) SetReadOnly(readOnly bool) {
+	f.SetFlags(f.flags.set(flagReadOnly, readOnly))
+}
+
+// frameFlags returns the flags of a frame, or zero if the frame
+// doesn't provide access to them.
+func frameFlags(frame Frame) FrameFlags {
+	if f, ok := frame.(interface {
+		Flags() FrameFlags
+	}); ok {
+		return f.Flags()
+	}
+
+	return 0
+}
+
+// Serialize returns the encoded header of a frame whose body, not
+// including the header, is size bytes long. Implementations of
+// Frame.Encode should write it before the body.
+func (f FrameHeader) Serialize(size int) []byte {
 	out := make([]byte, 10)
 	copy(out, f.id)
 
@@ -226,118 +415,289 @@ func (f FrameHeader) serialize(size int) []byte {
 	return out
 }
 
-func (f TextInformationFrame) size() int {
-	if f.FrameHeader.ID() == "TRDA" {
+// encoder holds the settings for converting text when writing
+// frames.
+type encoder struct {
+	policy EncodingPolicy
+	// Replaces runes that cannot be represented in ISO-8859-1 in
+	// frames that require it. If 0, such runes cause an error.
+	replacement rune
+}
+
+// toISO88591 converts text that has to be stored as ISO-8859-1, like
+// URLs.
+func (e encoder) toISO88591(s string) ([]byte, error) {
+	return utf8ToISO88591([]byte(s), e.replacement)
+}
+
+// lastToISO88591 converts the last text of a frame like toISO88591,
+// terminating text that ends in a null byte like
+// Encoding.lastFromUTF8.
+func (e encoder) lastToISO88591(s string) ([]byte, error) {
+	b, err := e.toISO88591(s)
+	if strings.HasSuffix(s, "\x00") {
+		b = append(b, 0)
+	}
+
+	return b, err
+}
+
+// textFrame is implemented by frames that contain text and whose
+// encoding depends on an encoder. Their Size and Encode methods use
+// the zero encoder, which writes UTF-8 and doesn't replace runes.
+type textFrame interface {
+	size(e encoder) int
+	encode(w io.Writer, e encoder) error
+}
+
+// encodeFrame writes a frame, using the encoder if the frame contains
+// text.
+func encodeFrame(w io.Writer, frame Frame, e encoder) error {
+	if f, ok := frame.(textFrame); ok {
+		return f.encode(w, e)
+	}
+
+	return frame.Encode(w)
+}
+
+// frameSize returns the size of a frame as written by encodeFrame.
+func frameSize(frame Frame, e encoder) int {
+	if f, ok := frame.(textFrame); ok {
+		return f.size(e)
+	}
+
+	return frame.Size()
+}
+
+// writeFrame writes a frame consisting of header and body, or returns
+// err if building the body failed.
+func writeFrame(w io.Writer, header FrameHeader, body [][]byte, err error) error {
+	if err != nil {
+		return err
+	}
+
+	err = writeMany(w, header.Serialize(bodySize(body)))
+	if err != nil {
+		return err
+	}
+
+	return writeMany(w, body...)
+}
+
+func bodySize(body [][]byte) int {
+	size := 0
+	for _, b := range body {
+		size += len(b)
+	}
+
+	return size
+}
+
+// languageBytes returns the three byte language code of a frame. Codes
+// of the wrong length are replaced with "XXX", which denotes an
+// unknown language.
+func languageBytes(language string) []byte {
+	if len(language) != 3 {
+		return []byte("XXX")
+	}
+
+	return []byte(language)
+}
+
+func (f TextInformationFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Text)
+	return [][]byte{{byte(enc)}, enc.lastFromUTF8(f.Text)}, nil
+}
+
+func (f TextInformationFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f TextInformationFrame) size(e encoder) int {
+	if f.obsolete() {
 		return 0
 	}
 
-	return frameLength + len(f.Text) + 1
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f TextInformationFrame) Encode(w io.Writer) error {
+	return f.encode(w, encoder{})
+}
+
+// encode writes the frame, or nothing for obsolete frames, which
+// callers may want to log.
+func (f TextInformationFrame) encode(w io.Writer, e encoder) error {
+	if f.obsolete() {
+		return nil
+	}
+
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
+}
+
+// obsolete reports whether the frame is one of the v2.3 frames that
+// cannot be expressed in v2.4, and therefore isn't written.
+func (f TextInformationFrame) obsolete() bool {
 	switch f.FrameHeader.ID() {
 	case "TRDA", "TSIZ":
-		Logging.Println("Not writing header", f.FrameHeader.ID())
-		return nil
-	default:
-		return writeMany(w,
-			f.FrameHeader.serialize(f.size()-frameLength),
-			utf8byte,
-			[]byte(f.Text),
-		)
+		return true
 	}
+
+	return false
 }
 
 func (f TextInformationFrame) Value() string {
 	return f.Text
 }
 
-func (f UserTextInformationFrame) size() int {
-	return frameLength + len(f.Description) + len(f.Text) + 2
+func (f UserTextInformationFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Description, f.Text)
+	return [][]byte{
+		{byte(enc)},
+		enc.fromUTF8(f.Description),
+		enc.terminator(),
+		enc.lastFromUTF8(f.Text),
+	}, nil
+}
+
+func (f UserTextInformationFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f UserTextInformationFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f UserTextInformationFrame) Encode(w io.Writer) error {
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		utf8byte,
-		[]byte(f.Description),
-		nul,
-		[]byte(f.Text),
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f UserTextInformationFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f UserTextInformationFrame) Value() string {
 	return f.Text
 }
 
-func (f UniqueFileIdentifierFrame) size() int {
-	iso := utf8.toISO88591([]byte(f.Owner))
-	return frameLength + len(f.Identifier) + len(iso) + 1
+func (f UniqueFileIdentifierFrame) body(e encoder) ([][]byte, error) {
+	owner, err := e.toISO88591(f.Owner)
+	return [][]byte{owner, nul, f.Identifier}, err
+}
+
+func (f UniqueFileIdentifierFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f UniqueFileIdentifierFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f UniqueFileIdentifierFrame) Encode(w io.Writer) error {
-	iso := utf8.toISO88591([]byte(f.Owner))
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		iso,
-		nul,
-		f.Identifier,
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f UniqueFileIdentifierFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f UniqueFileIdentifierFrame) Value() string {
 	return string(f.Identifier)
 }
 
-func (f URLLinkFrame) size() int {
-	return frameLength + len(utf8.toISO88591([]byte(f.URL)))
+func (f URLLinkFrame) body(e encoder) ([][]byte, error) {
+	url, err := e.lastToISO88591(f.URL)
+	return [][]byte{url}, err
+}
+
+func (f URLLinkFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f URLLinkFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f URLLinkFrame) Encode(w io.Writer) error {
-	iso := utf8.toISO88591([]byte(f.URL))
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		iso,
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f URLLinkFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f URLLinkFrame) Value() string {
 	return f.URL
 }
 
-func (f UserDefinedURLLinkFrame) size() int {
-	iso := utf8.toISO88591([]byte(f.URL))
-	return frameLength + len(f.Description) + len(iso) + 2
+func (f UserDefinedURLLinkFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Description)
+	url, err := e.lastToISO88591(f.URL)
+	return [][]byte{
+		{byte(enc)},
+		enc.fromUTF8(f.Description),
+		enc.terminator(),
+		url,
+	}, err
+}
+
+func (f UserDefinedURLLinkFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f UserDefinedURLLinkFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f UserDefinedURLLinkFrame) Encode(w io.Writer) error {
-	iso := utf8.toISO88591([]byte(f.URL))
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		utf8byte,
-		[]byte(f.Description),
-		nul,
-		iso,
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f UserDefinedURLLinkFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f UserDefinedURLLinkFrame) Value() string {
 	return f.URL
 }
 
-func (f CommentFrame) size() int {
-	return frameLength + len(f.Description) + len(f.Text) + 5
+func (f CommentFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Description, f.Text)
+	return [][]byte{
+		{byte(enc)},
+		languageBytes(f.Language),
+		enc.fromUTF8(f.Description),
+		enc.terminator(),
+		enc.lastFromUTF8(f.Text),
+	}, nil
+}
+
+func (f CommentFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f CommentFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f CommentFrame) Encode(w io.Writer) error {
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		utf8byte,
-		[]byte(f.Language),
-		[]byte(f.Description),
-		nul,
-		[]byte(f.Text),
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f CommentFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f CommentFrame) Value() string {
@@ -348,13 +708,13 @@ func (f PrivateFrame) Value() string {
 	return string(f.Data)
 }
 
-func (f PrivateFrame) size() int {
+func (f PrivateFrame) Size() int {
 	return frameLength + len(f.Owner) + len(f.Data) + len(nul)
 }
 
 func (f PrivateFrame) Encode(w io.Writer) error {
 	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
+		f.FrameHeader.Serialize(f.Size()-frameLength),
 		f.Owner,
 		nul,
 		f.Data,
@@ -365,41 +725,49 @@ func (f PictureFrame) Value() string {
 	return string(f.Data)
 }
 
-func (f PictureFrame) size() int {
-	return frameLength +
-		1 +
-		len(utf8.toISO88591([]byte(f.MIMEType))) +
-		len(nul) +
-		1 +
-		len(f.Description) +
-		len(nul) +
-		len(f.Data)
+func (f PictureFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Description)
+	mimeType, err := e.toISO88591(f.MIMEType)
+	return [][]byte{
+		{byte(enc)},
+		mimeType,
+		nul,
+		{byte(f.PictureType)},
+		enc.fromUTF8(f.Description),
+		enc.terminator(),
+		f.Data,
+	}, err
+}
+
+func (f PictureFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f PictureFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f PictureFrame) Encode(w io.Writer) error {
-	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		utf8byte,
-		utf8.toISO88591([]byte(f.MIMEType)),
-		nul,
-		[]byte{byte(f.PictureType)},
-		[]byte(f.Description),
-		nul,
-		f.Data,
-	)
+	return f.encode(w, encoder{})
+}
+
+func (f PictureFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
 }
 
 func (f MusicCDIdentifierFrame) Value() string {
 	return string(f.TOC)
 }
 
-func (f MusicCDIdentifierFrame) size() int {
+func (f MusicCDIdentifierFrame) Size() int {
 	return frameLength + len(f.TOC)
 }
 
 func (f MusicCDIdentifierFrame) Encode(w io.Writer) error {
 	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
+		f.FrameHeader.Serialize(f.Size()-frameLength),
 		f.TOC,
 	)
 }
@@ -408,29 +776,126 @@ func (f UnsynchronisedLyricsFrame) Value() string {
 	return f.Lyrics
 }
 
-func (f UnsynchronisedLyricsFrame) size() int {
-	return frameLength + 5 + len(f.Description) + len(f.Lyrics)
+func (f UnsynchronisedLyricsFrame) body(e encoder) ([][]byte, error) {
+	enc := e.policy.encoding(f.Description, f.Lyrics)
+	return [][]byte{
+		{byte(enc)},
+		languageBytes(f.Language),
+		enc.fromUTF8(f.Description),
+		enc.terminator(),
+		enc.lastFromUTF8(f.Lyrics),
+	}, nil
+}
+
+func (f UnsynchronisedLyricsFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f UnsynchronisedLyricsFrame) size(e encoder) int {
+	body, _ := f.body(e)
+	return frameLength + bodySize(body)
 }
 
 func (f UnsynchronisedLyricsFrame) Encode(w io.Writer) error {
+	return f.encode(w, encoder{})
+}
+
+func (f UnsynchronisedLyricsFrame) encode(w io.Writer, e encoder) error {
+	body, err := f.body(e)
+	return writeFrame(w, f.FrameHeader, body, err)
+}
+
+func (MPEGLocationLookupTableFrame) Value() string {
+	return ""
+}
+
+func (f MPEGLocationLookupTableFrame) Size() int {
+	bits := len(f.References) * (int(f.BitsForBytesDeviation) + int(f.BitsForMillisecondsDeviation))
+	return frameLength + 10 + (bits+7)/8
+}
+
+func (f MPEGLocationLookupTableFrame) Encode(w io.Writer) error {
+	bw := new(bitWriter)
+	for _, ref := range f.References {
+		bw.write(ref.BytesDeviation, f.BitsForBytesDeviation)
+		bw.write(ref.MillisecondsDeviation, f.BitsForMillisecondsDeviation)
+	}
+
 	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
-		utf8byte,
-		[]byte(f.Language),
-		[]byte(f.Description),
-		nul,
-		[]byte(f.Lyrics),
+		f.FrameHeader.Serialize(f.Size()-frameLength),
+		intToBytes(f.FramesBetweenReference)[2:],
+		intToBytes(f.BytesBetweenReference)[1:],
+		intToBytes(f.MillisecondsBetweenReference)[1:],
+		[]byte{f.BitsForBytesDeviation, f.BitsForMillisecondsDeviation},
+		bw.bytes(),
+	)
+}
+
+func (AudioSeekPointIndexFrame) Value() string {
+	return ""
+}
+
+func (f AudioSeekPointIndexFrame) Size() int {
+	return frameLength + 11 + len(f.Points)*int(f.BitsPerPoint/8)
+}
+
+func (f AudioSeekPointIndexFrame) Encode(w io.Writer) error {
+	points := make([]byte, 0, len(f.Points)*int(f.BitsPerPoint/8))
+	for _, p := range f.Points {
+		if f.BitsPerPoint == 16 {
+			points = append(points, byte(p>>8))
+		}
+		points = append(points, byte(p))
+	}
+
+	return writeMany(w,
+		f.FrameHeader.Serialize(f.Size()-frameLength),
+		intToBytes(int(f.DataStart)),
+		intToBytes(int(f.DataLength)),
+		intToBytes(len(f.Points))[2:],
+		[]byte{f.BitsPerPoint},
+		points,
+	)
+}
+
+func (f PositionSynchronisationFrame) Value() string {
+	return strconv.FormatUint(f.Position, 10)
+}
+
+func (f PositionSynchronisationFrame) Size() int {
+	return frameLength + 1 + len(uintToBytes(f.Position))
+}
+
+func (f PositionSynchronisationFrame) Encode(w io.Writer) error {
+	return writeMany(w,
+		f.FrameHeader.Serialize(f.Size()-frameLength),
+		[]byte{byte(f.TimestampFormat)},
+		uintToBytes(f.Position),
+	)
+}
+
+func (f SeekFrame) Value() string {
+	return strconv.FormatInt(f.MinimumOffset, 10)
+}
+
+func (f SeekFrame) Size() int {
+	return frameLength + 4
+}
+
+func (f SeekFrame) Encode(w io.Writer) error {
+	return writeMany(w,
+		f.FrameHeader.Serialize(f.Size()-frameLength),
+		intToBytes(int(f.MinimumOffset)),
 	)
 }
 
-func (f UnsupportedFrame) size() int {
+func (f UnsupportedFrame) Size() int {
 	return frameLength + len(f.Data)
 }
 
 func (f UnsupportedFrame) Encode(w io.Writer) error {
-	// TODO check header if unsupported frame should be dropped or copied verbatim
 	return writeMany(w,
-		f.FrameHeader.serialize(f.size()-frameLength),
+		f.FrameHeader.Serialize(f.Size()-frameLength),
 		f.Data,
 	)
 }
@@ -441,6 +906,10 @@ func (UnsupportedFrame) Value() string {
 }
 
 func readTXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	if frameSize < 1 {
+		return nil, ErrMalformedFrame
+	}
+
 	var encoding Encoding
 	frame := UserTextInformationFrame{FrameHeader: header}
 	rest := make([]byte, frameSize-1)
@@ -449,15 +918,22 @@ func readTXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 	if err != nil {
 		return nil, err
 	}
-	parts := splitNullN(rest, encoding, 2)
+	if !encoding.valid() {
+		return nil, ErrMalformedFrame
+	}
+	description, text := splitPair(rest, encoding)
 
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Text = string(encoding.toUTF8(parts[1]))
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Text = string(encoding.toUTF8(text))
 
 	return frame, nil
 }
 
 func readWXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	if frameSize < 1 {
+		return nil, ErrMalformedFrame
+	}
+
 	var encoding Encoding
 	frame := UserDefinedURLLinkFrame{FrameHeader: header}
 	rest := make([]byte, frameSize-1)
@@ -466,10 +942,13 @@ func readWXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 	if err != nil {
 		return nil, err
 	}
+	if !encoding.valid() {
+		return nil, ErrMalformedFrame
+	}
 
-	parts := splitNullN(rest, encoding, 2)
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.URL = string(iso88591ToUTF8(parts[1]))
+	description, url := splitPair(rest, encoding)
+	frame.Description = string(encoding.toUTF8(description))
+	frame.URL = string(iso88591.toUTF8(url))
 
 	return frame, nil
 }
@@ -483,9 +962,9 @@ func readUFIDFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return nil, err
 	}
 
-	parts := bytes.SplitN(rest, []byte{0}, 2)
-	frame.Owner = string(iso88591.toUTF8(parts[0]))
-	frame.Identifier = parts[1]
+	owner, identifier := splitPair(rest, iso88591)
+	frame.Owner = string(iso88591.toUTF8(owner))
+	frame.Identifier = identifier
 
 	return frame, nil
 }
@@ -497,19 +976,25 @@ func readCOMMFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		language [3]byte
 		rest     []byte
 	)
+	if frameSize < 4 {
+		return nil, ErrMalformedFrame
+	}
 	rest = make([]byte, frameSize-4)
 
 	err := readBinary(r, &encoding, &language, &rest)
 	if err != nil {
 		return nil, err
 	}
+	if !encoding.valid() {
+		return nil, ErrMalformedFrame
+	}
 
-	parts := splitNullN(rest, encoding, 2)
+	description, text := splitPair(rest, encoding)
 
 	frame.Language = string(language[:])
 
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Text = string(encoding.toUTF8(parts[1]))
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Text = string(encoding.toUTF8(text))
 
 	return frame, nil
 }
@@ -517,14 +1002,12 @@ func readCOMMFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 func readPRIVFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
 	frame := PrivateFrame{FrameHeader: header}
 	data := make([]byte, frameSize)
-	_, err := r.Read(data)
+	_, err := io.ReadFull(r, data)
 	if err != nil {
 		return frame, err
 	}
 
-	parts := bytes.SplitN(data, nul, 2)
-	frame.Owner = parts[0]
-	frame.Data = parts[1]
+	frame.Owner, frame.Data = splitPair(data, iso88591)
 
 	return frame, nil
 }
@@ -535,19 +1018,29 @@ func readAPICFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		encoding Encoding
 		rest     []byte
 	)
+	if frameSize < 1 {
+		return nil, ErrMalformedFrame
+	}
 	rest = make([]byte, frameSize-1)
 	err := readBinary(r, &encoding, &rest)
 	if err != nil {
 		return frame, err
 	}
+	if !encoding.valid() {
+		return nil, ErrMalformedFrame
+	}
 
-	parts1 := bytes.SplitN(rest, nul, 2)
-	parts2 := splitNullN(parts1[1][1:], encoding, 2)
+	mime, rest := splitPair(rest, iso88591)
+	if len(rest) < 1 {
+		// The picture type is missing.
+		return nil, ErrMalformedFrame
+	}
+	description, data := splitPair(rest[1:], encoding)
 
-	frame.MIMEType = string(iso88591.toUTF8(parts1[0]))
-	frame.PictureType = PictureType(parts1[1][0])
-	frame.Description = string(encoding.toUTF8(parts2[0]))
-	frame.Data = parts2[1]
+	frame.MIMEType = string(iso88591.toUTF8(mime))
+	frame.PictureType = PictureType(rest[0])
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Data = data
 
 	return frame, nil
 }
@@ -555,7 +1048,7 @@ func readAPICFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 func readMCDIFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
 	frame := MusicCDIdentifierFrame{FrameHeader: header}
 	frame.TOC = make([]byte, frameSize)
-	_, err := r.Read(frame.TOC)
+	_, err := io.ReadFull(r, frame.TOC)
 	return frame, err
 }
 
@@ -566,18 +1059,146 @@ func readUSLTFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		language [3]byte
 		rest     []byte
 	)
+	if frameSize < 4 {
+		return nil, ErrMalformedFrame
+	}
 	rest = make([]byte, frameSize-4)
 
 	err := readBinary(r, &encoding, &language, rest)
 	if err != nil {
 		return frame, err
 	}
+	if !encoding.valid() {
+		return nil, ErrMalformedFrame
+	}
 
-	parts := splitNullN(rest, encoding, 2)
+	description, lyrics := splitPair(rest, encoding)
 	frame.Language = string(language[:])
 
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Lyrics = string(encoding.toUTF8(parts[1]))
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Lyrics = string(encoding.toUTF8(lyrics))
+
+	return frame, nil
+}
+
+func readMLLTFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := MPEGLocationLookupTableFrame{FrameHeader: header}
+	if frameSize < 10 {
+		return nil, ErrMalformedFrame
+	}
+
+	data := make([]byte, frameSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.FramesBetweenReference = int(data[0])<<8 | int(data[1])
+	frame.BytesBetweenReference = int(data[2])<<16 | int(data[3])<<8 | int(data[4])
+	frame.MillisecondsBetweenReference = int(data[5])<<16 | int(data[6])<<8 | int(data[7])
+	frame.BitsForBytesDeviation = data[8]
+	frame.BitsForMillisecondsDeviation = data[9]
+
+	bitsBytes := int(frame.BitsForBytesDeviation)
+	bitsMillis := int(frame.BitsForMillisecondsDeviation)
+	if bitsBytes > 32 || bitsMillis > 32 {
+		return nil, ErrMalformedFrame
+	}
+	if bitsBytes+bitsMillis == 0 {
+		return frame, nil
+	}
+
+	// The references are padded to a whole number of bytes. Bits
+	// that don't make up a whole reference are ignored.
+	br := &bitReader{data: data[10:]}
+	bits := bitsBytes + bitsMillis
+	n := len(br.data) * 8 / bits
+	frame.References = make([]MPEGLocationReference, n)
+	for i := range frame.References {
+		frame.References[i].BytesDeviation = br.read(frame.BitsForBytesDeviation)
+		frame.References[i].MillisecondsDeviation = br.read(frame.BitsForMillisecondsDeviation)
+	}
+	// Padding can make up whole references if they are shorter than
+	// a byte. References of zeros that start in the last byte, after
+	// the bits the previous references needed, are such padding.
+	for n > 0 && (n-1)*bits > (len(br.data)-1)*8 && frame.References[n-1] == (MPEGLocationReference{}) {
+		n--
+	}
+	frame.References = frame.References[:n]
+
+	return frame, nil
+}
+
+func readASPIFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := AudioSeekPointIndexFrame{FrameHeader: header}
+	if frameSize < 11 {
+		return nil, ErrMalformedFrame
+	}
+
+	data := make([]byte, frameSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.DataStart = int64(binary.BigEndian.Uint32(data[0:4]))
+	frame.DataLength = int64(binary.BigEndian.Uint32(data[4:8]))
+	n := int(data[8])<<8 | int(data[9])
+	frame.BitsPerPoint = data[10]
+	if frame.BitsPerPoint != 8 && frame.BitsPerPoint != 16 {
+		return nil, ErrMalformedFrame
+	}
+
+	width := int(frame.BitsPerPoint / 8)
+	points := data[11:]
+	if len(points) < n*width {
+		return nil, ErrMalformedFrame
+	}
+
+	frame.Points = make([]uint16, n)
+	for i := range frame.Points {
+		if width == 2 {
+			frame.Points[i] = uint16(points[2*i])<<8 | uint16(points[2*i+1])
+		} else {
+			frame.Points[i] = uint16(points[i])
+		}
+	}
+
+	return frame, nil
+}
+
+func readPOSSFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := PositionSynchronisationFrame{FrameHeader: header}
+	if frameSize < 2 || frameSize > 9 {
+		return nil, ErrMalformedFrame
+	}
+
+	data := make([]byte, frameSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.TimestampFormat = TimestampFormat(data[0])
+	for _, b := range data[1:] {
+		frame.Position = frame.Position<<8 | uint64(b)
+	}
+
+	return frame, nil
+}
+
+func readSEEKFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := SeekFrame{FrameHeader: header}
+	if frameSize != 4 {
+		return nil, ErrMalformedFrame
+	}
+
+	var offset uint32
+	err := binary.Read(r, binary.BigEndian, &offset)
+	if err != nil {
+		return nil, err
+	}
+	frame.MinimumOffset = int64(offset)
 
 	return frame, nil
 }
@@ -0,0 +1,254 @@
+package id3
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrReadOnly is returned when saving a file that was opened from a
+// file system that doesn't implement WriteFS.
+var ErrReadOnly = errors.New("id3: file was opened read-only")
+
+// A WritableFile is an open file that tags can be saved to. *os.File
+// implements it.
+type WritableFile interface {
+	fs.File
+	io.ReaderAt
+	io.WriterAt
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+	Sync() error
+}
+
+// A WriteFS is a file system that files can be saved in. Names follow
+// the rules of fs.FS.
+type WriteFS interface {
+	fs.FS
+	// OpenFile opens a file like os.OpenFile. It has to support the
+	// flags os.O_RDWR, os.O_CREATE and os.O_EXCL.
+	OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+	// Rename replaces newname with oldname, ideally atomically.
+	Rename(oldname, newname string) error
+	Remove(name string) error
+}
+
+// DirFS returns a WriteFS for the files in the directory dir of the
+// operating system.
+func DirFS(dir string) WriteFS {
+	return dirFS(dir)
+}
+
+// dirFS implements WriteFS on top of package os. The empty dirFS,
+// which Open uses, accepts paths of the operating system instead of
+// fs.FS names.
+type dirFS string
+
+func (dir dirFS) join(op, name string) (string, error) {
+	if dir == "" {
+		return name, nil
+	}
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return filepath.Join(string(dir), filepath.FromSlash(name)), nil
+}
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+	return dir.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (dir dirFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	full, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(full, flag, perm)
+	if err != nil {
+		// Don't return a typed nil.
+		return nil, err
+	}
+	return f, nil
+}
+
+func (dir dirFS) Rename(oldname, newname string) error {
+	oldpath, err := dir.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := dir.join("rename", newname)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(newpath))
+	return nil
+}
+
+func (dir dirFS) Remove(name string) error {
+	full, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(full)
+}
+
+func (dir dirFS) chtimes(name string, mtime time.Time) error {
+	full, err := dir.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(full, time.Now(), mtime)
+}
+
+// OpenFS opens the named file in fsys and parses its tag, like Open.
+// The file has to implement io.ReaderAt or io.ReadSeeker. If fsys
+// implements WriteFS, the file is opened for writing, and Save
+// replaces it using a temporary file in the same directory of fsys.
+// Otherwise, Save fails with ErrReadOnly.
+func OpenFS(fsys fs.FS, name string) (*File, error) {
+	return openFS(fsys, name, nil)
+}
+
+// OpenFSWithOptions opens a file like OpenFS, reading and saving it
+// according to opts.
+func OpenFSWithOptions(fsys fs.FS, name string, opts Options) (*File, error) {
+	return openFS(fsys, name, &opts)
+}
+
+func openFS(fsys fs.FS, name string, opts *Options) (*File, error) {
+	// TODO improve documentation. HasTag() will only be false until
+	// the first save; and there will be an empty tag to work with.
+	wfs, writable := fsys.(WriteFS)
+
+	var file fs.File
+	var err error
+	if writable {
+		file, err = wfs.OpenFile(name, os.O_RDWR, 0)
+	} else {
+		file, err = fsys.Open(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newFile(file, wfs, name, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func newFile(file fs.File, fsys WriteFS, name string, opts *Options) (*File, error) {
+	var src io.ReaderAt
+	switch file := file.(type) {
+	case io.ReaderAt:
+		src = file
+	case io.ReadSeeker:
+		src = readSeekerAt{file}
+	default:
+		return nil, errors.New("id3: file implements neither io.ReaderAt nor io.ReadSeeker")
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := parse(namedReader{io.NewSectionReader(src, 0, stat.Size()), name}, opts)
+	if err != nil {
+		if _, ok := err.(notATagHeader); !ok {
+			return nil, err
+		}
+	}
+
+	f := &File{
+		src:      src,
+		fsys:     fsys,
+		name:     name,
+		fileSize: stat.Size(),
+		Tag:      tag,
+	}
+	if fsys != nil {
+		f.f = file.(WritableFile)
+	}
+
+	err = f.layout(f.HasTag())
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.mergeTags()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// namedReader provides parse with the name of the file it reads, for
+// logging.
+type namedReader struct {
+	*io.SectionReader
+	name string
+}
+
+func (r namedReader) Name() string {
+	return r.name
+}
+
+// target returns the file system and name of the file that Save
+// replaces. Symbolic links on disk are resolved, so that the file they
+// point to gets replaced instead of the link.
+func (f *File) target() (WriteFS, string, error) {
+	dir, ok := f.fsys.(dirFS)
+	if !ok {
+		return f.fsys, f.name, nil
+	}
+
+	full, err := dir.join("save", f.name)
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return dirFS(""), path, nil
+}
+
+// createTemp creates a new file in fsys next to the named file, for
+// replacing it.
+func createTemp(fsys WriteFS, target string, perm fs.FileMode) (string, WritableFile, error) {
+	// Names are either paths of the operating system or fs.FS names,
+	// which use slashes.
+	i := strings.LastIndexAny(target, "/"+string(filepath.Separator))
+	prefix := target[:i+1] + "." + target[i+1:] + ".tmp"
+
+	for try := 0; ; try++ {
+		name := prefix + strconv.FormatUint(uint64(rand.Int63()), 36)
+		tmp, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, fs.ErrExist) && try < 10 {
+			continue
+		}
+		return name, tmp, err
+	}
+}
@@ -0,0 +1,162 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memFS is an in-memory WriteFS.
+type memFS map[string]*[]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m memFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	data, ok := m[name]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case ok && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !ok:
+		data = new([]byte)
+		m[name] = data
+	}
+
+	return &memFile{name: name, data: data}, nil
+}
+
+func (m memFS) Rename(oldname, newname string) error {
+	m[newname] = m[oldname]
+	delete(m, oldname)
+	return nil
+}
+
+func (m memFS) Remove(name string) error {
+	delete(m, name)
+	return nil
+}
+
+type memFile struct {
+	name string
+	data *[]byte
+	off  int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return fs.Stat(fstest.MapFS{f.name: {Data: *f.data}}, f.name)
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(*f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, (*f.data)[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(*f.data)) {
+		f.Truncate(end)
+	}
+	return copy((*f.data)[off:], p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(*f.data))
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if size <= int64(len(*f.data)) {
+		*f.data = (*f.data)[:size]
+	} else {
+		*f.data = append(*f.data, make([]byte, size-int64(len(*f.data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Sync() error  { return nil }
+func (f *memFile) Close() error { return nil }
+
+func TestOpenFS(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	audio := bytes.Repeat([]byte{0xAA}, 1000)
+	data := append(buf.Bytes(), audio...)
+
+	fsys := memFS{"dir/test.mp3": &data}
+	f, err := OpenFS(fsys, "dir/test.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Title() != "Title" {
+		t.Fatalf("Title is %q", f.Title())
+	}
+
+	// A large frame forces replacing the file.
+	f.SetTextFrame("TIT3", string(bytes.Repeat([]byte("la "), 2000)))
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if len(fsys) != 1 {
+		t.Fatalf("Temporary file wasn't removed: %d files", len(fsys))
+	}
+
+	saved := *fsys["dir/test.mp3"]
+	if !bytes.HasSuffix(saved, audio) {
+		t.Fatal("Audio data was modified")
+	}
+	parsed, err := Parse(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title() != "Title" || parsed.GetTextFrame("TIT3") == "" {
+		t.Fatalf("Unexpected tag after saving: %q", parsed.Title())
+	}
+
+	ro := fstest.MapFS{"test.mp3": &fstest.MapFile{Data: saved, ModTime: time.Now()}}
+	f, err = OpenFS(ro, "test.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Title() != "Title" {
+		t.Fatalf("Title is %q", f.Title())
+	}
+	if err := f.Save(); err != ErrReadOnly {
+		t.Fatalf("Expected ErrReadOnly, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzParse checks that parsing arbitrary input doesn't panic, and
+// that parsed tags survive encoding: the encoded tag parses again,
+// and encoding it once more yields the same bytes.
+func FuzzParse(f *testing.F) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetTextFrameSlice("TPE1", []string{"One", "Two"})
+	tag.SetComments([]Comment{{Language: "eng", Description: "d", Text: "Comment"}})
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tag, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		encoded := new(bytes.Buffer)
+		if err := tag.Encode(encoded); err != nil {
+			t.Fatalf("Cannot encode parsed tag: %v", err)
+		}
+		parsed, err := Parse(bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			t.Fatalf("Cannot parse encoded tag: %v", err)
+		}
+
+		// Compare only the frames, because encoding a tag updates its
+		// tagging time.
+		first := new(bytes.Buffer)
+		if err := tag.encodeFrames(first, tag.logger()); err != nil {
+			t.Fatalf("Cannot encode frames: %v", err)
+		}
+		second := new(bytes.Buffer)
+		if err := parsed.encodeFrames(second, parsed.logger()); err != nil {
+			t.Fatalf("Cannot encode reparsed frames: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("Encoding isn't stable:\n%q\n%q", first.Bytes(), second.Bytes())
+		}
+	})
+}
+
+// fuzzFrame checks that decoding arbitrary bodies of frames with the
+// given ID doesn't panic, that decoded frames can be encoded with the
+// size they report, and that encoding is stable across decoding.
+func fuzzFrame(f *testing.F, id FrameType, seeds ...string) {
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		frame, err := readFrame(bytes.NewReader(rawFrame(string(id), 0, body)), 0x0400)
+		if err != nil {
+			return
+		}
+
+		first := new(bytes.Buffer)
+		if err := frame.Encode(first); err != nil {
+			t.Fatalf("Cannot encode decoded frame: %v", err)
+		}
+		if first.Len() != frame.Size() {
+			t.Fatalf("Encoded %d bytes, but size is %d", first.Len(), frame.Size())
+		}
+
+		decoded, err := readFrame(bytes.NewReader(first.Bytes()), 0x0400)
+		if err != nil {
+			t.Fatalf("Cannot decode encoded frame: %v", err)
+		}
+		second := new(bytes.Buffer)
+		if err := decoded.Encode(second); err != nil {
+			t.Fatalf("Cannot encode redecoded frame: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("Encoding isn't stable:\n%q\n%q", first.Bytes(), second.Bytes())
+		}
+	})
+}
+
+func FuzzTextFrame(f *testing.F) {
+	fuzzFrame(f, "TIT2", "\x00Title", "\x01\xFF\xFEA\x00B", "\x03One\x00Two", "")
+}
+
+func FuzzURLFrame(f *testing.F) {
+	fuzzFrame(f, "WOAR", "http://example.com", "")
+}
+
+func FuzzTXXXFrame(f *testing.F) {
+	fuzzFrame(f, "TXXX", "\x00desc\x00text", "\x01\xFF\xFEd\x00\x00\x00\xFF\xFEt\x00", "\x00desc")
+}
+
+func FuzzWXXXFrame(f *testing.F) {
+	fuzzFrame(f, "WXXX", "\x00desc\x00http://example.com", "\x03")
+}
+
+func FuzzUFIDFrame(f *testing.F) {
+	fuzzFrame(f, "UFID", "http://musicbrainz.org\x00id", "owner")
+}
+
+func FuzzCOMMFrame(f *testing.F) {
+	fuzzFrame(f, "COMM", "\x00engdesc\x00text", "\x01eng\xFF\xFE\x00\x00\xFF\xFEt", "\x00en")
+}
+
+func FuzzPRIVFrame(f *testing.F) {
+	fuzzFrame(f, "PRIV", "owner\x00data", "owner")
+}
+
+func FuzzAPICFrame(f *testing.F) {
+	fuzzFrame(f, "APIC", "\x00image/png\x00\x03desc\x00data", "\x00image/png", "\x01image/jpeg\x00\x00\xFF\xFEd")
+}
+
+func FuzzMCDIFrame(f *testing.F) {
+	fuzzFrame(f, "MCDI", "toc")
+}
+
+func FuzzUSLTFrame(f *testing.F) {
+	fuzzFrame(f, "USLT", "\x00engdesc\x00lyrics", "\x00eng")
+}
+
+func FuzzMLLTFrame(f *testing.F) {
+	fuzzFrame(f, "MLLT", "\x00\x01\x00\x01\xA0\x00\x00\x1A\x04\x04\x12\x34")
+}
+
+func FuzzASPIFrame(f *testing.F) {
+	fuzzFrame(f, "ASPI", "\x00\x00\x00\x00\x00\x00\x10\x00\x00\x02\x08\x10\x20")
+}
+
+func FuzzPOSSFrame(f *testing.F) {
+	fuzzFrame(f, "POSS", "\x02\x00\x01")
+}
+
+func FuzzSEEKFrame(f *testing.F) {
+	fuzzFrame(f, "SEEK", "\x00\x00\x10\x00")
+}
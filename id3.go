@@ -2,13 +2,13 @@ package id3
 
 import (
 	"bytes"
-	"errors"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,18 +17,29 @@ import (
 // TODO reevaluate TagHeader. Right now it's a snapshot of the past
 // that doesn't reflect the present
 
-// The amount of padding that will be added after the last frame.
+// The package-level variables below are the defaults for tags and
+// files that weren't created with Options.
+
+// The amount of padding that will be added after the last frame,
+// unless Tag.Padding specifies a policy.
 var Padding = 1024
 
-// Enables logging if set to true.
+// Enables logging to the standard logger of package log if set to
+// true. Use Options.Logger for structured logging.
 var Logging LogFlag
 
 // The size limit in bytes for in-memory buffers when rewriting files before
 // falling back to temporary files.
 var InMemoryThreshold = int64(1024 * 1024 * 10) // 10 MB
 
+// A LogFlag is a Logger that either logs to the standard logger of
+// package log or discards all messages.
 type LogFlag bool
 
+// Println logs its arguments like log.Println if l is true.
+//
+// Deprecated: The library logs structured messages using Debug and
+// Warn.
 func (l LogFlag) Println(args ...interface{}) {
 	if l {
 		log.Println(args...)
@@ -38,15 +49,21 @@ func (l LogFlag) Println(args ...interface{}) {
 const (
 	frameLength   = 10
 	tagHeaderSize = 10
+	tagFooterSize = 10
 )
 
+const footerFlag HeaderFlags = 16
+
 var (
 	id3byte     = []byte("ID3")
+	footerByte  = []byte("3DI")
 	versionByte = []byte{4, 0}
 )
 
 const TimeFormat = "2006-01-02T15:04:05"
 
+// timeFormats are the formats of timestamps, ordered from
+// PrecisionSecond to PrecisionYear.
 var timeFormats = []string{
 	"2006-01-02T15:04:05",
 	"2006-01-02T15:04",
@@ -92,13 +109,63 @@ type TagHeader struct {
 type Tag struct {
 	Header TagHeader
 	Frames FramesMap
+
+	// Order determines the order in which frames are written. Frames
+	// that were read from a file keep their original order, only
+	// new frames are placed according to Order. If nil,
+	// DefaultFrameOrder is used.
+	Order FrameOrder
+
+	// Encoding determines the text encoding of written frames.
+	Encoding EncodingPolicy
+
+	// ISO88591Replacement replaces runes that cannot be represented
+	// in frames that have to use ISO-8859-1, like URLs. If 0,
+	// encoding such frames fails with an UnrepresentableError
+	// instead.
+	ISO88591Replacement rune
+
+	// If Lossless is true, frames that haven't been modified since
+	// they were read from a v2.4 tag are written using their
+	// original bytes, and no TDTG frame gets added.
+	Lossless bool
+
+	// Padding determines the padding of tags that are written anew.
+	// If nil, Padding bytes are used.
+	Padding *PaddingPolicy
+
+	// Restrictions, if not nil, are the ID3v2.4 tag restrictions
+	// that Validate checks the tag against.
+	Restrictions *Restrictions
+
+	// Upgrades lists the changes made while upgrading a tag read
+	// from an older version to v2.4.
+	Upgrades []UpgradeAction
+
+	opts     *Options    // If nil, the package-level defaults are used
+	order    []FrameType // Order of frame IDs in the parsed tag
+	path     string      // Path of the file the tag was read from, for logging
+	filtered bool        // Whether Options.Filter skipped frames
+	padding  int         // Padding of the tag as read or last saved
 }
 
 type File struct {
-	f           *os.File
-	fileSize    int64
-	audioReader io.ReadSeeker
-	HasTags     bool // true if the actual file has tags
+	f            WritableFile // nil if the file was opened read-only
+	src          io.ReaderAt  // Source of the tag and audio data, usually f
+	fsys         WriteFS      // File system containing the file
+	name         string       // Name of the file in fsys
+	fileSize     int64
+	audioStart   int64 // Offset of the audio data in the file
+	audioEnd     int64 // End of the audio data in the file
+	trailerStart int64 // Offset of data following the audio and an appended tag, e.g. an ID3v1 tag
+	audioReader  io.ReadSeeker
+	trailer      io.ReadSeeker
+	HasTags      bool // true if the actual file has tags
+
+	// If Append is true, Save will write the tag to the end of the
+	// file, followed by a footer, instead of to the beginning. Open
+	// sets it for files that only have an appended tag.
+	Append bool
 	*Tag
 }
 
@@ -114,19 +181,46 @@ func NewTag() *Tag {
 }
 
 func (t *Tag) Encode(w io.Writer) error {
-	t.SetTextFrameTime("TDTG", time.Now().UTC())
-	header := generateHeader(t.Frames.size() + Padding)
-	_, err := w.Write(header)
+	return t.encode(w, false, t.saveOptions())
+}
+
+// encode writes the tag according to opts. Tags with a footer must
+// not contain padding, so none will be written in that case.
+func (t *Tag) encode(w io.Writer, footer bool, opts Options) error {
+	if err := t.checkStrict(opts); err != nil {
+		return err
+	}
+	log := t.loggerFor(&opts)
+	t.setTaggingTime()
+	t.removeTagAlterationFrames(log)
+
+	var flags HeaderFlags
+	framesSize := t.framesSize()
+	padding := opts.Padding.padding(framesSize, t.padding)
+	if footer {
+		flags |= footerFlag
+		padding = 0
+	}
+
+	size := framesSize + padding
+	_, err := w.Write(generateHeader(size, flags))
 	if err != nil {
 		return err
 	}
 
-	err = t.Frames.Encode(w)
+	err = t.encodeFrames(w, log)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.Write(make([]byte, Padding))
+	_, err = w.Write(make([]byte, padding))
+	if err != nil {
+		return err
+	}
+
+	if footer {
+		_, err = w.Write(generateFooter(size, flags))
+	}
 	return err
 }
 
@@ -151,8 +245,9 @@ func (p PictureType) String() string {
 // TODO: FrameFlags.String()
 
 var (
-	ErrNoExtendedHeader = errors.New("id3: no support for extended headers")
+	ErrNoExtendedHeader    = errors.New("id3: no support for extended headers")
 	ErrNoUnsynchronizedTag = errors.New("id3: no support for unsynchronized tags")
+	ErrMalformedFrame      = errors.New("id3: malformed frame")
 )
 
 func (err notATagHeader) Error() string {
@@ -179,32 +274,120 @@ func (f HeaderFlags) Experimental() bool {
 	return (f & 32) > 0
 }
 
+// Footer reports whether the tag is followed by a footer. Footers
+// only exist in v2.4 tags.
+func (f HeaderFlags) Footer() bool {
+	return (f & footerFlag) > 0
+}
+
 func (f HeaderFlags) UndefinedSet() bool {
-	return (f & 31) > 0
+	return (f & 15) > 0
 }
 
+// Frame flags use the layout of v2.4. Flags of older versions get
+// converted when reading frames.
+const (
+	flagDiscardOnTagAlteration  FrameFlags = 0x4000
+	flagDiscardOnFileAlteration FrameFlags = 0x2000
+	flagReadOnly                FrameFlags = 0x1000
+	flagGrouped                 FrameFlags = 0x0040
+	flagCompressed              FrameFlags = 0x0008
+	flagEncrypted               FrameFlags = 0x0004
+	flagDataLengthIndicator     FrameFlags = 0x0001
+)
+
 func (f FrameFlags) PreserveTagAlteration() bool {
-	return (f & 0x4000) == 0
+	return (f & flagDiscardOnTagAlteration) == 0
 }
 
 func (f FrameFlags) PreserveFileAlteration() bool {
-	return (f & 0x2000) == 0
+	return (f & flagDiscardOnFileAlteration) == 0
 }
 
 func (f FrameFlags) ReadOnly() bool {
-	return (f & 0x1000) > 0
+	return (f & flagReadOnly) > 0
 }
 
 func (f FrameFlags) Compressed() bool {
-	return (f & 128) > 0
+	return (f & flagCompressed) > 0
 }
 
 func (f FrameFlags) Encrypted() bool {
-	return (f & 64) > 0
+	return (f & flagEncrypted) > 0
 }
 
 func (f FrameFlags) Grouped() bool {
-	return (f & 32) > 0
+	return (f & flagGrouped) > 0
+}
+
+// status returns the status flags, which describe how to handle the
+// frame, without the format flags, which describe how its body is
+// encoded.
+func (f FrameFlags) status() FrameFlags {
+	return f & 0xFF00
+}
+
+func (f FrameFlags) set(flag FrameFlags, on bool) FrameFlags {
+	if on {
+		return f | flag
+	}
+
+	return f &^ flag
+}
+
+// upgradeFrameFlags converts frame flags from the layout of v2.3 to
+// that of v2.4. Compressed frames of v2.4 require a data length
+// indicator, which replaces the decompressed size of v2.3.
+func upgradeFrameFlags(f FrameFlags) FrameFlags {
+	// The status flags moved one bit to the right
+	out := (f & 0xE000) >> 1
+	out = out.set(flagCompressed, f&0x80 > 0)
+	out = out.set(flagEncrypted, f&0x40 > 0)
+	out = out.set(flagGrouped, f&0x20 > 0)
+	out = out.set(flagDataLengthIndicator, f&0x80 > 0)
+
+	return out
+}
+
+// upgradeFrameData converts the data that v2.3 adds to the header of
+// compressed, encrypted or grouped frames, which precedes their body,
+// to the layout of v2.4: the group, the encryption method and the
+// data length indicator, in that order, instead of the decompressed
+// size, the encryption method and the group.
+func upgradeFrameData(flags FrameFlags, data []byte) ([]byte, error) {
+	var size, method, group []byte
+	take := func(n int) []byte {
+		if len(data) < n {
+			return nil
+		}
+		b := data[:n]
+		data = data[n:]
+		return b
+	}
+	if flags.Compressed() {
+		if size = take(4); size == nil {
+			return nil, ErrMalformedFrame
+		}
+	}
+	if flags.Encrypted() {
+		if method = take(1); method == nil {
+			return nil, ErrMalformedFrame
+		}
+	}
+	if flags.Grouped() {
+		if group = take(1); group == nil {
+			return nil, ErrMalformedFrame
+		}
+	}
+	if size != nil {
+		var b [4]byte
+		copy(b[:], size)
+		size = intToBytes(synchsafeInt(int(binary.BigEndian.Uint32(b[:]))))
+	}
+
+	out := make([]byte, 0, len(group)+len(method)+len(size)+len(data))
+	out = append(append(append(out, group...), method...), size...)
+	return append(out, data...), nil
 }
 
 func (v Version) String() string {
@@ -256,8 +439,21 @@ func readHeader(r io.Reader) (header TagHeader, err error) {
 // readFrame reads the next ID3 frame. It expects the reader to be
 // seeked to right before the frame. It also expects that the reader
 // can't read beyond the last frame. readFrame will return io.EOF if
-// there are no more frames to read.
-func readFrame(r io.Reader) (Frame, error) {
+// there are no more frames to read. version is the version of the
+// tag the frame belongs to.
+func readFrame(r io.Reader, version Version) (Frame, error) {
+	header, frameSize, err := readFrameHeader(r, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrameBody(r, header, frameSize)
+}
+
+// readFrameHeader reads the header of the next frame, like readFrame.
+// It returns the header, whose raw bytes are the 10 header bytes, and
+// the size of the frame's body.
+func readFrameHeader(r io.Reader, version Version) (FrameHeader, int, error) {
 	var (
 		headerBytes struct {
 			ID    [4]byte
@@ -272,14 +468,14 @@ func readFrame(r io.Reader) (Frame, error) {
 		if err == io.ErrUnexpectedEOF {
 			// If we couldn't read the header assume we were at the
 			// end of the tag.
-			return nil, io.EOF
+			return header, 0, io.EOF
 		}
-		return nil, err
+		return header, 0, err
 	}
 
 	// We're in the padding, return io.EOF
 	if headerBytes.ID == [4]byte{0, 0, 0, 0} {
-		return nil, io.EOF
+		return header, 0, io.EOF
 	}
 
 	for _, byte := range headerBytes.ID {
@@ -293,29 +489,83 @@ func readFrame(r io.Reader) (Frame, error) {
 			continue
 		}
 
-		return nil, NotAFrameHeader{headerBytes}
+		return header, 0, NotAFrameHeader{headerBytes}
 	}
 
 	header.id = FrameType(headerBytes.ID[:])
-	header.flags = FrameFlags(int16(headerBytes.Flags[0])<<8 | int16(headerBytes.Flags[1]))
+	header.flags = FrameFlags(uint16(headerBytes.Flags[0])<<8 | uint16(headerBytes.Flags[1]))
+	if version < 0x0400 {
+		header.flags = upgradeFrameFlags(header.flags)
+	}
 	frameSize := desynchsafeInt(headerBytes.Size)
 
-	if header.flags.Compressed() {
-		panic("not implemented: cannot read compressed frame")
-		// TODO: Read decompressed size (4 bytes)
+	header.raw = make([]byte, frameLength)
+	copy(header.raw, headerBytes.ID[:])
+	copy(header.raw[4:], headerBytes.Size[:])
+	copy(header.raw[8:], headerBytes.Flags[:])
+	// Frames of older versions differ in their encoding and cannot
+	// be written verbatim.
+	header.legacy = version < 0x0400
+
+	return header, frameSize, nil
+}
+
+// readFrameBody reads the body of a frame whose header was read by
+// readFrameHeader.
+func readFrameBody(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	// Read the entire frame up front. The frame readers slice
+	// binary data out of it, so the original bytes are kept around
+	// at little extra cost.
+	raw, err := readFrameData(r, header, frameSize)
+	if err != nil {
+		return nil, err
+	}
+	header.raw = raw
+	header.orig = new(snapshot)
+
+	frame, err := decodeFrameBody(bytes.NewReader(raw[frameLength:]), header, frameSize)
+	if cerr, ok := err.(codecError); ok {
+		// The undecoded frame stands in for the original.
+		header.orig.frame = cerr.frame
+	}
+	if err != nil {
+		return nil, err
 	}
+	header.orig.frame = frame
 
-	if header.flags.Encrypted() {
-		panic("not implemented: cannot read encrypted frame")
-		// TODO: Read encryption method (1 byte)
+	return frame, nil
+}
+
+// decodeFrameBody decodes the body of a frame, which r reads from
+// header.raw.
+func decodeFrameBody(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	var err error
+
+	// TODO: Support compressed, encrypted and grouped frames. Until
+	// then, their bodies are kept as they are.
+	if header.flags.Compressed() || header.flags.Encrypted() || header.flags.Grouped() {
+		data := header.raw[frameLength:]
+		if header.legacy {
+			data, err = upgradeFrameData(header.flags, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return UnsupportedFrame{
+			FrameHeader: header,
+			Data:        data,
+		}, nil
 	}
 
-	if header.flags.Grouped() {
-		panic("not implemented: cannot read grouped frame")
-		// TODO: Read group identifier (1 byte)
+	if codec, ok := lookupFrameCodec(header.id); ok {
+		return readCustomFrame(r, header, frameSize, codec)
 	}
 
 	if header.id[0] == 'T' && header.id != "TXXX" {
+		if frameSize < 1 {
+			return nil, ErrMalformedFrame
+		}
+
 		var encoding Encoding
 		frame := TextInformationFrame{FrameHeader: header}
 		information := make([]byte, frameSize-1)
@@ -323,6 +573,9 @@ func readFrame(r io.Reader) (Frame, error) {
 		if err != nil {
 			return nil, err
 		}
+		if !encoding.valid() {
+			return nil, ErrMalformedFrame
+		}
 
 		frame.Text = string(encoding.toUTF8(information))
 
@@ -332,7 +585,7 @@ func readFrame(r io.Reader) (Frame, error) {
 	if header.id[0] == 'W' && header.id != "WXXX" {
 		frame := URLLinkFrame{FrameHeader: header}
 		url := make([]byte, frameSize)
-		_, err = r.Read(url)
+		_, err = io.ReadFull(r, url)
 		if err != nil {
 			return nil, err
 		}
@@ -357,14 +610,22 @@ func readFrame(r io.Reader) (Frame, error) {
 	case "MCDI":
 		return readMCDIFrame(r, header, frameSize)
 	case "USLT":
-		return readUFIDFrame(r, header, frameSize)
+		return readUSLTFrame(r, header, frameSize)
+	case "MLLT":
+		return readMLLTFrame(r, header, frameSize)
+	case "ASPI":
+		return readASPIFrame(r, header, frameSize)
+	case "POSS":
+		return readPOSSFrame(r, header, frameSize)
+	case "SEEK":
+		return readSEEKFrame(r, header, frameSize)
 	default:
 		data := make([]byte, frameSize)
-		n, err := r.Read(data)
+		_, err := io.ReadFull(r, data)
 
 		return UnsupportedFrame{
 			FrameHeader: header,
-			Data:        data[:n],
+			Data:        data,
 		}, err
 	}
 }
@@ -379,39 +640,67 @@ func NewFile(file *os.File, tag *Tag) (*File, error) {
 
 	f := &File{
 		f:        file,
+		src:      file,
+		fsys:     dirFS(""),
+		name:     file.Name(),
 		fileSize: stat.Size(),
 		Tag:      tag,
 	}
+	tag.path = file.Name()
 
-	f.audioReader = io.NewSectionReader(file, tagHeaderSize+int64(tag.Header.Size), f.fileSize-int64(tag.Header.Size))
+	err = f.layout(f.HasTag())
+	if err != nil {
+		return nil, err
+	}
 
 	return f, nil
 }
 
+// layout determines where the audio data, an appended tag and any
+// trailing data are located in the file. prepended specifies whether
+// the file starts with the tag described by f.Header.
+func (f *File) layout(prepended bool) error {
+	f.audioStart = 0
+	if prepended {
+		f.audioStart = tagHeaderSize + int64(f.Header.Size)
+		if f.Header.Flags.Footer() {
+			f.audioStart += tagFooterSize
+		}
+	}
+
+	start, end, err := f.locateAppendedTag()
+	if err != nil {
+		return err
+	}
+
+	f.audioEnd = start
+	f.trailerStart = end
+	f.audioReader = io.NewSectionReader(f.src, f.audioStart, f.audioEnd-f.audioStart)
+	f.trailer = io.NewSectionReader(f.src, f.trailerStart, f.fileSize-f.trailerStart)
+	return nil
+}
+
 // Open opens the file with the given name in RW mode and parses its
 // tag. If there is no tag, (*File).HasTag() will return false.
 //
 // Call Close() to close the underlying *os.File when done.
 func Open(name string) (*File, error) {
-	// TODO improve documentation. HasTag() will only be false until
-	// the first save; and there will be an empty tag to work with.
-	f, err := os.OpenFile(name, os.O_RDWR, 0)
-	if err != nil {
-		return nil, err
-	}
+	return open(name, nil)
+}
 
-	tag, err := Parse(f)
-	if err != nil {
-		if _, ok := err.(notATagHeader); !ok {
-			return nil, err
-		}
-	}
-	file, err := NewFile(f, tag)
+func open(name string, opts *Options) (*File, error) {
+	return openFS(dirFS(""), name, opts)
+}
+
+// mergeTags merges the frames of an appended tag and of tags pointed
+// to by SEEK frames into the file's tag.
+func (f *File) mergeTags() error {
+	err := f.mergeAppendedTag()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return file, nil
+	return f.mergeSeekTags()
 }
 
 // HasTag returns true when the underlying file has a tag.
@@ -419,10 +708,13 @@ func (f *File) HasTag() bool {
 	return f.Tag.Header.Version > 0
 }
 
-// Close closes the underlying os.File. You cannot use Save
+// Close closes the underlying file. You cannot use Save
 // afterwards.
 func (f *File) Close() error {
-	return f.f.Close()
+	if c, ok := f.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // ParseHeader parses only the ID3 header.
@@ -442,9 +734,46 @@ func ParseHeader(r io.Reader) (TagHeader, error) {
 // Parse will always return a valid tag. In the case of an error, the
 // tag will be empty.
 func Parse(r io.Reader) (*Tag, error) {
-	// TODO return how many bytes we read into the reader; so people
-	// know where the audio begins
+	return parse(r, nil)
+}
+
+// ParseCharset parses a tag like Parse, but decodes text that frames
+// claim to store as ISO-8859-1 with dec. Use it for tags written by
+// software that stored text in the system's legacy charset, like
+// Windows-1251 or Shift-JIS. See DetectCharset for choosing the charset
+// heuristically.
+func ParseCharset(r io.Reader, dec CharsetDecoder) (*Tag, error) {
+	opts := DefaultOptions()
+	opts.Charset = dec
+	return parse(r, &opts)
+}
+
+// parse parses a tag according to opts, using the package-level
+// defaults if opts is nil.
+func parse(r io.Reader, opts *Options) (*Tag, error) {
 	tag := NewTag()
+	if opts != nil {
+		tag.setOptions(*opts)
+	}
+	if named, ok := r.(interface{ Name() string }); ok {
+		tag.path = named.Name()
+	}
+	o := tag.options()
+
+	// Lazy frames of seekable sources are read when they're decoded.
+	var src io.ReaderAt
+	var offset int64
+	if rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok && o.Lazy {
+		pos, err := rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			src, offset = rs, pos+tagHeaderSize
+		}
+	}
+
+	avail := available(r)
 	header, err := ParseHeader(r)
 	if err != nil {
 		return tag, err
@@ -460,125 +789,150 @@ func Parse(r io.Reader) (*Tag, error) {
 		return nil, ErrNoUnsynchronizedTag
 	}
 
-	tagReader := io.LimitReader(r, int64(header.Size)+tagHeaderSize)
-	for {
-		frame, err := readFrame(tagReader)
+	if err := o.Limits.checkTag(header); err != nil {
+		return tag, err
+	}
+
+	tagReader := newTagReader(r, header.Size, avail)
+	tagSize := tagReader.N
+	for n := 1; ; n++ {
+		end := tagSize - tagReader.N
+		pos := offset + end
+		frameHeader, frameSize, err := readFrameHeader(tagReader, header.Version)
 		if err != nil {
 			if err == io.EOF {
+				tag.padding = int(tagSize - end)
 				break
 			}
 
 			return tag, err
 		}
-		tag.Frames[frame.ID()] = append(tag.Frames[frame.ID()], frame)
-	}
-
-	if header.Version < 0x0400 {
-		tag.upgrade()
-	}
-
-	return tag, nil
-}
-
-// upgrade upgrades tags from an older version to IDv2.4. It should
-// only be called for files that use an older version.
-func (t *Tag) upgrade() {
-	// Upgrade TYER/TDAT/TIME to TDRC if at least
-	// one of TYER, TDAT or TIME are set.
-	if t.HasFrame("TYER") || t.HasFrame("TDAT") || t.HasFrame("TIME") {
-		Logging.Println("Replacing TYER, TDAT and TIME with TDRC...")
-
-		year := t.GetTextFrameNumber("TYER")
-		date := t.GetTextFrame("TDAT")
-		tim := t.GetTextFrame("TIME")
-
-		if len(date) != 4 {
-			date = "0101"
+		if err := o.Limits.checkFrame(frameHeader, frameSize, n, tagReader.N); err != nil {
+			return tag, err
 		}
 
-		if len(tim) != 4 {
-			tim = "0000"
+		var frame Frame
+		switch {
+		case o.Filter != nil && !o.Filter(frameHeader.id):
+			tag.filtered = true
+			if err := skip(tagReader, int64(frameSize)); err != nil {
+				return tag, err
+			}
+			continue
+		case o.Lazy && isLazy(frameHeader.id):
+			frame, err = lazyFrame(tagReader, frameHeader, frameSize, src, pos, o.Charset)
+		default:
+			frame, err = readFrameBody(tagReader, frameHeader, frameSize)
 		}
-
-		day, _ := strconv.Atoi(date[0:2])
-		month, _ := strconv.Atoi(date[2:])
-		hour, _ := strconv.Atoi(date[0:2])
-		minute, _ := strconv.Atoi(date[2:])
-
-		t.SetRecordingTime(time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC))
-		t.RemoveFrames("TYER")
-		t.RemoveFrames("TDAT")
-		t.RemoveFrames("TIME")
-	}
-
-	// Upgrade Original Release Year to Original Release Time
-	if !t.HasFrame("TDOR") {
-		if t.HasFrame("XDOR") {
-			Logging.Println("Replacing XDOR with TDOR")
-			panic("not implemented") // FIXME
-		} else if t.HasFrame("TORY") {
-			Logging.Println("Replacing TORY with TDOR")
-
-			year := t.GetTextFrameNumber("TORY")
-			t.SetOriginalReleaseTime(time.Date(year, 0, 0, 0, 0, 0, 0, time.UTC))
+		if cerr, ok := err.(codecError); ok {
+			if o.Strict {
+				return tag, cerr.err
+			}
+			tag.logger().Warn("cannot decode frame with registered codec", "frame", frameHeader.id, "action", "kept undecoded frame", "error", cerr.err)
+			frame, err = cerr.frame, nil
+		}
+		if err != nil {
+			return tag, err
+		}
+		if _, ok := frame.(*LazyFrame); !ok && o.Charset != nil {
+			res, _, err := recodeFrame(frame, o.Charset)
+			if err != nil {
+				if o.Strict {
+					return tag, err
+				}
+				tag.logger().Warn("cannot decode frame with legacy charset", "frame", frame.ID(), "action", "kept original text", "error", err)
+			} else {
+				frame = res
+			}
+		}
+		if !tag.HasFrame(frame.ID()) {
+			tag.order = append(tag.order, frame.ID())
+		}
+		if !tag.addFrame(frame, o.Duplicates) && o.Strict {
+			return tag, DuplicateFrameError{frame.ID()}
 		}
 	}
 
-	for name := range t.Frames {
-		switch name {
-		case "TLAN", "TCON", "TPE1", "TOPE", "TCOM", "TEXT", "TOLY":
-			Logging.Println("Replacing / with x00 for", name)
-			t.SetTextFrameSlice(name, strings.Split(t.GetTextFrame(name), "/"))
-		}
+	if header.Version < 0x0400 {
+		tag.upgrade()
 	}
-	// TODO EQUA → EQU2
-	// TODO IPL → TMCL, TIPL
-	// TODO RVAD → RVA2
-	// TODO TRDA → TDRL
+
+	return tag, nil
 }
 
 // Clear removes all tags from the file.
 func (t *Tag) Clear() {
 	t.Frames = make(FramesMap)
+	t.order = nil
 }
 
 func (t *Tag) RemoveFrames(name FrameType) {
 	delete(t.Frames, name)
 }
 
-// Validate checks whether the tags are conforming to the
-// specification.
-//
-// This entails two checks: Whether only frames that are covered by
-// the specification are present and whether all values are within
-// valid ranges.
-//
-// It is well possible that reading existing files will result in
-// invalid tags.
-//
-// Calling Save() will not automatically validate the tags and will
-// happily write invalid tags.
-//
-// Assuming that the original file was valid and that only the
-// getter/setter methods were used the generated tags should always be
-// valid.
-func (t *Tag) Validate() error {
-	// TODO consider returning a list of errors, one per invalid frame,
-	// specifying the reason
+// removeTagAlterationFrames removes unknown frames that are flagged
+// to be discarded when the tag is altered.
+func (t *Tag) removeTagAlterationFrames(log Logger) {
+	t.removeFramesIf(log, func(frame Frame) bool {
+		return !frameFlags(frame).PreserveTagAlteration() && unknownFrame(frame)
+	})
+}
 
-	panic("not implemented") // FIXME
+// RemoveFileAlterationFrames removes unknown frames that are flagged
+// to be discarded when the audio data is altered. Call it after
+// modifying the audio data, e.g. by transcoding it, and before saving
+// the tag.
+func (t *Tag) RemoveFileAlterationFrames() {
+	t.removeFramesIf(t.logger(), func(frame Frame) bool {
+		return !frameFlags(frame).PreserveFileAlteration() && unknownFrame(frame)
+	})
+}
 
-	if t.HasFrame("TSRC") && len(t.GetTextFrame("TSRC")) != 12 {
-		// TODO invalid TSRC frame
+// unknownFrame reports whether the package doesn't understand a frame.
+// The specification only requires discarding unknown frames according
+// to the alteration preservation flags, as the package keeps the
+// frames it understands up to date itself.
+func unknownFrame(frame Frame) bool {
+	switch f := frame.(type) {
+	case UnsupportedFrame:
+		return true
+	case *LazyFrame:
+		decoded, err := f.Decode()
+		return err != nil || unknownFrame(decoded)
 	}
 
-	return nil
+	return false
+}
+
+func (t *Tag) removeFramesIf(log Logger, fn func(Frame) bool) {
+	for name, frames := range t.Frames {
+		kept := frames[:0]
+		for _, frame := range frames {
+			if fn(frame) {
+				log.Debug("discarding frame", "frame", name, "action", "discarded")
+				continue
+			}
+			kept = append(kept, frame)
+		}
+
+		if len(kept) == 0 {
+			delete(t.Frames, name)
+		} else {
+			t.Frames[name] = kept
+		}
+	}
 }
 
-// Sanitize will remove all frames that aren't valid. Check the
-// documentation of (*File).Validate() to see what "valid" means.
-func (t *Tag) Sanitize() {
-	panic("not implemented") // FIXME
+// mergeFrames adds the frames of other to the tag, unless the tag
+// already has frames with the same ID.
+func (t *Tag) mergeFrames(other *Tag) {
+	for id, frames := range other.Frames {
+		if id == "SEEK" || t.HasFrame(id) {
+			continue
+		}
+		t.logger().Debug("merging frames", "frame", id, "action", "merged")
+		t.Frames[id] = frames
+	}
 }
 
 func (t *Tag) Album() string {
@@ -747,22 +1101,6 @@ func (t *Tag) SetOwner(owner string) {
 	t.SetTextFrame("TOWN", owner)
 }
 
-func (t *Tag) RecordingTime() time.Time {
-	return t.GetTextFrameTime("TDRC")
-}
-
-func (t *Tag) SetRecordingTime(rt time.Time) {
-	t.SetTextFrameTime("TDRC", rt)
-}
-
-func (t *Tag) OriginalReleaseTime() time.Time {
-	return t.GetTextFrameTime("TDOR")
-}
-
-func (t *Tag) SetOriginalReleaseTime(rt time.Time) {
-	t.SetTextFrameTime("TDOR", rt)
-}
-
 func (t *Tag) OriginalFilename() string {
 	return t.GetTextFrame("TOFN")
 }
@@ -779,14 +1117,6 @@ func (t *Tag) SetPlaylistDelay(d time.Duration) {
 	t.SetTextFrameNumber("TDLY", int(d.Nanoseconds()/1e6))
 }
 
-func (t *Tag) EncodingTime() time.Time {
-	return t.GetTextFrameTime("TDEN")
-}
-
-func (t *Tag) SetEncodingTime(et time.Time) {
-	t.SetTextFrameTime("TDEN", et)
-}
-
 func (t *Tag) AlbumSortOrder() string {
 	return t.GetTextFrame("TSOA")
 }
@@ -829,31 +1159,66 @@ func (t *Tag) SetMood(mood string) {
 
 func (t *Tag) Comments() []Comment {
 	frames := t.Frames["COMM"]
-	comments := make([]Comment, len(frames))
+	comments := make([]Comment, 0, len(frames))
 
-	for i, frame := range frames {
-		comment := frame.(CommentFrame)
-		comments[i] = Comment{
+	for _, frame := range frames {
+		// Comments that couldn't be decoded, for example compressed
+		// ones, are skipped.
+		comment, ok := frame.(CommentFrame)
+		if !ok {
+			continue
+		}
+		comments = append(comments, Comment{
 			Language:    comment.Language,
 			Description: comment.Description,
 			Text:        comment.Text,
-		}
+		})
 	}
 
 	return comments
 }
 
+// SetComments replaces all comments. Status flags of existing comments with
+// the same language and description are preserved, and read-only
+// comments are kept unchanged. Of comments with the same language and
+// description, only the last one is kept.
 func (t *Tag) SetComments(comments []Comment) {
-	frames := make([]Frame, len(comments))
-	for i, comment := range comments {
-		frames[i] = CommentFrame{
-			FrameHeader: FrameHeader{
-				id: "COMM",
-			},
+	old := t.Frames["COMM"]
+
+	var frames []Frame
+	for _, frame := range old {
+		if frameFlags(frame).ReadOnly() {
+			t.logger().Debug("not modifying read-only frame", "frame", "COMM", "action", "kept")
+			frames = append(frames, frame)
+		}
+	}
+
+	seen := make(map[string]int)
+
+	for _, comment := range comments {
+		header := FrameHeader{id: "COMM"}
+		key := comment.Language + "\x00" + comment.Description
+		if i := t.findFrame("COMM", key); i >= 0 {
+			if frameFlags(old[i]).ReadOnly() {
+				continue
+			}
+			header.flags = frameFlags(old[i]).status()
+		}
+
+		frame := CommentFrame{
+			FrameHeader: header,
 			Language:    comment.Language,
 			Description: comment.Description,
 			Text:        comment.Text,
 		}
+		if j, ok := seen[key]; ok {
+			// Later comments replace earlier ones with the same
+			// language and description.
+			frames[j] = frame
+			continue
+		}
+		seen[key] = len(frames)
+		frames = append(frames, frame)
 	}
 	t.Frames["COMM"] = frames
 }
@@ -889,8 +1254,8 @@ func (t *Tag) getUserTextFrame(name string) string {
 	}
 
 	for _, frame := range frames {
-		userFrame := frame.(UserTextInformationFrame)
-		if userFrame.Description == name {
+		userFrame, ok := frame.(UserTextInformationFrame)
+		if ok && userFrame.Description == name {
 			return userFrame.Text
 		}
 	}
@@ -917,19 +1282,11 @@ func (t *Tag) GetTextFrameSlice(name FrameType) []string {
 	return strings.Split(s, "\x00")
 }
 
+// GetTextFrameTime returns the first timestamp stored in a text frame
+// as a time, or the zero time if the frame is missing or malformed.
+// Use GetTextFrameTimestamp to learn the timestamp's precision.
 func (t *Tag) GetTextFrameTime(name FrameType) time.Time {
-	s := t.GetTextFrame(name)
-	if s == "" {
-		return time.Time{}
-	}
-
-	ft, err := parseTime(s)
-	if err != nil {
-		// FIXME figure out a way to signal format errors
-		panic(err)
-	}
-
-	return ft
+	return t.GetTextFrameTimestamp(name).Time
 }
 
 func (t *Tag) SetTextFrame(name FrameType, value string) {
@@ -939,48 +1296,19 @@ func (t *Tag) SetTextFrame(name FrameType, value string) {
 		return
 	}
 
-	frames, ok := t.Frames[name]
-	if !ok {
-		frames = make([]Frame, 1)
-		t.Frames[name] = frames
-	}
-	frames[0] = TextInformationFrame{
-		FrameHeader: FrameHeader{
-			id: name,
-		},
-		Text: value,
-	}
-	// TODO what about flags and preserving them?
+	t.setFrame(name, "", func(header FrameHeader) Frame {
+		return TextInformationFrame{FrameHeader: header, Text: value}
+	})
 }
 
 func (t *Tag) setUserTextFrame(name string, value string) {
-	// Set/create a user text frame
-	frame := UserTextInformationFrame{
-		FrameHeader: FrameHeader{id: "TXXX"},
-		Description: name,
-		Text:        value,
-	}
-
-	frames, ok := t.Frames["TXXX"]
-	if !ok {
-		frames = make([]Frame, 0)
-		t.Frames["TXXX"] = frames
-	}
-
-	var i int
-	for i = range frames {
-		if frames[i].(UserTextInformationFrame).Description == name {
-			ok = true
-			break
+	t.setFrame("TXXX", name, func(header FrameHeader) Frame {
+		return UserTextInformationFrame{
+			FrameHeader: header,
+			Description: name,
+			Text:        value,
 		}
-	}
-
-	if ok {
-		frames[i] = frame
-	} else {
-		t.Frames["TXXX"] = append(t.Frames["TXXX"], frame)
-	}
-
+	})
 }
 
 func (t *Tag) SetTextFrameNumber(name FrameType, value int) {
@@ -991,155 +1319,168 @@ func (t *Tag) SetTextFrameSlice(name FrameType, value []string) {
 	t.SetTextFrame(name, strings.Join(value, "\x00"))
 }
 
+// SetTextFrameTime stores a time with a precision of seconds. Use
+// SetTextFrameTimestamp for other precisions.
 func (t *Tag) SetTextFrameTime(name FrameType, value time.Time) {
 	t.SetTextFrame(name, value.Format(TimeFormat))
 }
 
 // TODO all the other methods
 
-// UserTextFrames returns all TXXX frames.
+// UserTextFrames returns all decoded TXXX frames.
 func (t *Tag) UserTextFrames() []UserTextInformationFrame {
-	res := make([]UserTextInformationFrame, len(t.Frames["TXXX"]))
-	for i, frame := range t.Frames["TXXX"] {
-		res[i] = frame.(UserTextInformationFrame)
+	res := make([]UserTextInformationFrame, 0, len(t.Frames["TXXX"]))
+	for _, frame := range t.Frames["TXXX"] {
+		if f, ok := frame.(UserTextInformationFrame); ok {
+			res = append(res, f)
+		}
 	}
 
 	return res
 }
 
-func (f *File) saveInplace(framesSize int) error {
-	// TODO consider writing headers/frames into buffer first, to
-	// not break existing file in case of error
-	header := generateHeader(f.Header.Size)
-
-	_, err := f.f.Seek(0, 0)
-	if err != nil {
-		return err
-	}
-
-	_, err = f.f.Write(header)
-	if err != nil {
-		return err
-	}
-
-	err = f.Frames.Encode(f.f)
-	if err != nil {
-		return err
-	}
-
+// setSavedHeader updates the header to describe the tag that has just
+// been written by Save according to opts.
+func (f *File) setSavedHeader(framesSize int, opts Options) {
 	f.Header.Version = 0x0400
-	// Blank out remainder of previous tags
-	_, err = f.f.Write(make([]byte, f.Header.Size-framesSize))
-	return err
+	if f.Append {
+		f.Header.Size = framesSize
+		f.Header.Flags = footerFlag
+		f.padding = 0
+	} else {
+		f.padding = opts.Padding.padding(framesSize, f.padding)
+		f.Header.Size = framesSize + f.padding
+		f.Header.Flags = 0
+	}
 }
 
-func (f *File) saveNew(framesSize int) error {
-	var buf io.ReadWriter
+// Save saves the tags to the file. If the changed tags fit into the
+// existing file, they will be overwritten in place. Otherwise the
+// entire file will be rewritten, with padding according to the tag's
+// padding policy. If Append is set, the tag will be
+// written to the end of the file instead, which only requires
+// rewriting the entire file if it had a tag at its beginning.
+//
+// Unsupported frames that are flagged to be discarded when the tag is
+// altered will be removed.
+//
+// If you require backups, you need to create them yourself.
+func (f *File) Save() error {
+	return f.save(f.saveOptions())
+}
 
-	// Work in memory If the old file was smaller than 10MiB, use
-	// a temporary file otherwise.
-	if f.fileSize < InMemoryThreshold {
-		Logging.Println("Working in memory")
-		buf = new(bytes.Buffer)
-	} else {
-		Logging.Println("Using a temporary file")
-		newFile, err := ioutil.TempFile("", "id3")
-		if err != nil {
-			return err
-		}
-		defer os.Remove(newFile.Name())
-		buf = newFile
+// save saves the file according to opts, which take the place of the
+// file's options and padding policy.
+func (f *File) save(opts Options) error {
+	if f.f == nil {
+		return ErrReadOnly
 	}
-
-	err := f.SaveTo(buf)
-	if err != nil {
+	if f.filtered {
+		return ErrFiltered
+	}
+	// Lazy frames refer to the file, which is about to change.
+	if err := f.DecodeFrames(); err != nil {
 		return err
 	}
-
-	// We successfully generated a new file, so replace the old
-	// one with it.
-	err = truncate(f.f)
-	if err != nil {
+	if err := f.checkStrict(opts); err != nil {
 		return err
 	}
+	log := f.loggerFor(&opts)
+	f.setTaggingTime()
+	f.removeTagAlterationFrames(log)
+	framesSize := f.framesSize()
 
-	if newFile, ok := buf.(*os.File); ok {
-		_, err = newFile.Seek(0, 0)
-		if err != nil {
-			return err
+	if f.Append {
+		if f.audioStart == 0 {
+			log.Debug("saving", "action", "write appended tag")
+			return f.saveAppended(framesSize, opts)
 		}
+		log.Debug("saving", "action", "write new file")
+		return f.saveNew(framesSize, opts)
 	}
 
-	_, err = io.Copy(f.f, buf)
-	if err != nil {
-		return err
-	}
-
-	f.Header.Size = framesSize + Padding
-	f.Header.Version = 0x0400
-	return nil
-}
-
-// Save saves the tags to the file. If the changed tags fit into the
-// existing file, they will be overwritten in place. Otherwise the
-// entire file will be rewritten.
-//
-// If you require backups, you need to create them yourself.
-func (f *File) Save() error {
-	f.SetTextFrameTime("TDTG", time.Now().UTC())
-	framesSize := f.Frames.size()
-
-	if f.HasTag() && f.Header.Size >= framesSize && len(f.Frames) > 0 {
+	room := f.audioStart - tagHeaderSize - int64(framesSize)
+	if f.audioStart > 0 && f.audioEnd == f.trailerStart && room >= 0 && len(f.Frames) > 0 {
 		// The file already has tags and there's enough room to write
 		// ours.
-		Logging.Println("Writing in-place")
-		return f.saveInplace(framesSize)
+		log.Debug("saving", "action", "write in place")
+		return f.saveInplace(framesSize, opts)
 	}
 	// We have to create a new file
-	Logging.Println("Writing new file")
-	return f.saveNew(framesSize)
+	log.Debug("saving", "action", "write new file")
+	return f.saveNew(framesSize, opts)
 }
 
-func (fm FramesMap) size() int {
-	size := 0
-	for _, frames := range fm {
-		for _, frame := range frames {
-			size += frame.size()
-		}
-	}
+func (t *Tag) encoder() encoder {
+	return encoder{policy: t.Encoding, replacement: t.ISO88591Replacement}
+}
 
-	return size
+// setTaggingTime updates the TDTG frame, unless the tag is in lossless
+// mode.
+func (t *Tag) setTaggingTime() {
+	if !t.Lossless {
+		t.SetTextFrameTime("TDTG", time.Now().UTC())
+	}
 }
 
-func (fm FramesMap) Encode(w io.Writer) (err error) {
-	// TODO write important frames first
-	for _, frames := range fm {
-		for _, frame := range frames {
-			err := frame.Encode(w)
-			if err != nil {
-				return err
-			}
-		}
+// Encode writes all frames, ordered by DefaultFrameOrder.
+func (fm FramesMap) Encode(w io.Writer) error {
+	ids := make([]FrameType, 0, len(fm))
+	for id := range fm {
+		ids = append(ids, id)
 	}
+	sort.Slice(ids, func(i, j int) bool {
+		return DefaultFrameOrder(ids[i], ids[j])
+	})
 
-	return
+	return fm.encode(w, ids)
 }
 
+// SaveTo writes the tag, the audio data and any data following it,
+// e.g. an ID3v1 tag, to w. If Append is set, the tag will be written
+// after the audio data.
 func (f *File) SaveTo(w io.Writer) error {
+	return f.saveTo(w, f.saveOptions())
+}
+
+// saveTo writes the file like SaveTo, according to opts.
+func (f *File) saveTo(w io.Writer, opts Options) error {
 	// TODO document that this will not update version/HasTag/... for
 	// this *File
-	err := f.Tag.Encode(w)
-	if err != nil {
-		return err
+	if !f.Append {
+		err := f.Tag.encode(w, false, opts)
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err = f.audioReader.Seek(0, 0)
+	_, err := f.audioReader.Seek(0, 0)
 	if err != nil {
 		return err
 	}
 
 	// Copy audio data
 	_, err = io.Copy(w, f.audioReader)
+	if err != nil {
+		return err
+	}
+
+	if f.Append {
+		err = f.Tag.encode(w, true, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.trailer == nil {
+		return nil
+	}
+
+	_, err = f.trailer.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f.trailer)
 	return err
 }
 
@@ -1162,7 +1503,18 @@ func synchsafeInt(i int) int {
 	return (i & 0x7f) |
 		((i & 0x3f80) << 1) |
 		((i & 0x1fc000) << 2) |
-		((i & 0xfe0000) << 3)
+		((i & 0xfe00000) << 3)
+}
+
+// uintToBytes returns the big endian representation of i, using as
+// few bytes as possible but at least one.
+func uintToBytes(i uint64) []byte {
+	out := []byte{byte(i)}
+	for i >>= 8; i > 0; i >>= 8 {
+		out = append([]byte{byte(i)}, out...)
+	}
+
+	return out
 }
 
 func intToBytes(i int) []byte {
@@ -1174,6 +1526,17 @@ func intToBytes(i int) []byte {
 	}
 }
 
+// splitPair splits data at the first null terminator. A missing
+// terminator is tolerated, and results in an empty second part.
+func splitPair(data []byte, encoding Encoding) ([]byte, []byte) {
+	parts := splitNullN(data, encoding, 2)
+	if len(parts) < 2 {
+		return parts[0], nil
+	}
+
+	return parts[0], parts[1]
+}
+
 func splitNullN(data []byte, encoding Encoding, n int) [][]byte {
 	if encoding == utf8 || encoding == iso88591 {
 		return bytes.SplitN(data, nul, n)
@@ -1184,37 +1547,22 @@ func splitNullN(data []byte, encoding Encoding, n int) [][]byte {
 		prev    int
 	)
 
-	for i := 0; i < len(data); i += 2 {
-		// TODO if there's no data[i+1] then this is malformed data
-		// and we should return an error
+	for i := 0; i+1 < len(data) && (n < 0 || len(matches) < n-1); i += 2 {
 		if data[i] == 0 && data[i+1] == 0 {
 			matches = append(matches, data[prev:i])
-
-			if len(matches) == n-1 {
-				break
-			}
+			prev = i + 2
 		}
 	}
 
-	if prev < len(data)-1 {
-		matches = append(matches, data[prev:])
-	}
-
-	return matches
+	return append(matches, data[prev:])
 }
 
-func parseTime(input string) (res time.Time, err error) {
-	for _, format := range timeFormats {
-		res, err = time.Parse(format, input)
-		if err == nil {
-			break
-		}
-	}
-
-	return
+func parseTime(input string) (time.Time, error) {
+	ts, err := ParseTimestamp(input)
+	return ts.Time, err
 }
 
-func truncate(f *os.File) error {
+func truncate(f WritableFile) error {
 	err := f.Truncate(0)
 	if err != nil {
 		return err
@@ -1223,7 +1571,7 @@ func truncate(f *os.File) error {
 	return err
 }
 
-func generateHeader(size int) []byte {
+func generateHeader(size int, flags HeaderFlags) []byte {
 	buf := new(bytes.Buffer)
 
 	size = synchsafeInt(size)
@@ -1231,13 +1579,21 @@ func generateHeader(size int) []byte {
 	writeMany(buf,
 		id3byte,
 		versionByte,
-		nul, // TODO flags
+		[]byte{byte(flags)},
 		intToBytes(size),
 	)
 
 	return buf.Bytes()
 }
 
+// generateFooter returns a footer, which is a copy of the header with
+// a different identifier.
+func generateFooter(size int, flags HeaderFlags) []byte {
+	footer := generateHeader(size, flags)
+	copy(footer, footerByte)
+	return footer
+}
+
 func frameNameToUserFrame(name FrameType) (frameName string, ok bool) {
 	if len(name) < 6 {
 		return "", false
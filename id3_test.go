@@ -2,6 +2,9 @@ package id3
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 )
@@ -23,9 +26,9 @@ func TestUTF8ToISO88591(t *testing.T) {
 	in := []byte("Ein etwas kürzerer Text mit wenigen Umlauten: äöüß äöüß")
 	out := []byte("Ein etwas k\xFCrzerer Text mit wenigen Umlauten: \xE4\xF6\xFC\xDF \xE4\xF6\xFC\xDF")
 
-	res := utf8ToISO88591(in)
+	res, err := utf8ToISO88591(in, 0)
 
-	if !bytes.Equal(res, out) {
+	if err != nil || !bytes.Equal(res, out) {
 		t.Fail()
 	}
 }
@@ -41,6 +44,53 @@ func TestISO88591ToUTF8(t *testing.T) {
 	}
 }
 
+func TestISO88591CodePoints(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		iso := []byte{byte(i)}
+		utf := []byte(string(rune(i)))
+
+		if res := iso88591ToUTF8(iso); !bytes.Equal(res, utf) {
+			t.Errorf("iso88591ToUTF8(%#x) = %x, expected %x", i, res, utf)
+		}
+		res, err := utf8ToISO88591(utf, 0)
+		if err != nil || !bytes.Equal(res, iso) {
+			t.Errorf("utf8ToISO88591(%U) = %x, %v, expected %x", i, res, err, iso)
+		}
+	}
+}
+
+func TestUTF8ToISO88591Unrepresentable(t *testing.T) {
+	in := []byte("a€b日")
+
+	_, err := utf8ToISO88591(in, 0)
+	if err != (UnrepresentableError{'€'}) {
+		t.Fatalf("Expected error for '€', got %v", err)
+	}
+
+	res, err := utf8ToISO88591(in, '?')
+	if err != nil || string(res) != "a?b?" {
+		t.Fatalf("Got %q, %v, expected %q", res, err, "a?b?")
+	}
+
+	tag := NewTag()
+	tag.Frames["WOAR"] = []Frame{URLLinkFrame{FrameHeader: FrameHeader{id: "WOAR"}, URL: "http://例え.jp/"}}
+	if err := tag.Encode(ioutil.Discard); err == nil {
+		t.Fatal("Encoding unrepresentable URL succeeded")
+	}
+	tag.ISO88591Replacement = '?'
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := out.Frames["WOAR"][0].Value(); url != "http://??.jp/" {
+		t.Fatalf("URL is %q, expected %q", url, "http://??.jp/")
+	}
+}
+
 func TestUTF16ToUTF8(t *testing.T) {
 	in := []byte{254, 255, 0, 74, 0,
 		117, 0, 115, 0, 116, 0, 32, 0, 97, 0, 32, 0, 116, 0, 101, 0, 115,
@@ -83,6 +133,65 @@ func TestUTF16LEToUTF8(t *testing.T) {
 	}
 }
 
+func TestUTF16MultipleValues(t *testing.T) {
+	in := []byte{0xFF, 0xFE, 'a', 0, 0, 0, 0xFE, 0xFF, 0, 'b', 0, 0}
+	out := []byte("a\x00b")
+
+	res := utf16bom.toUTF8(in)
+
+	if !bytes.Equal(res, out) {
+		t.Errorf("Expected: %q - Got: %q", out, res)
+	}
+}
+
+func TestEncodingPolicy(t *testing.T) {
+	tests := []struct {
+		policy EncodingPolicy
+		text   string
+		out    Encoding
+	}{
+		{AlwaysUTF8, "äöü", utf8},
+		{AlwaysUTF16, "abc", utf16bom},
+		{PreferISO88591, "äöü", iso88591},
+		{PreferISO88591, "日本語", utf16bom},
+	}
+
+	for _, test := range tests {
+		tag := NewTag()
+		tag.Encoding = test.policy
+		tag.SetTitle(test.text)
+		tag.Frames["COMM"] = []Frame{CommentFrame{
+			FrameHeader: FrameHeader{id: "COMM"},
+			Language:    "eng",
+			Description: "Description",
+			Text:        test.text,
+		}}
+
+		buf := new(bytes.Buffer)
+		if err := tag.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		parsed, err := Parse(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Title() != test.text {
+			t.Errorf("Title is %q, expected %q", parsed.Title(), test.text)
+		}
+		comments := parsed.Comments()
+		if len(comments) != 1 || comments[0].Text != test.text || comments[0].Description != "Description" {
+			t.Errorf("Unexpected comments %v", comments)
+		}
+
+		i := bytes.Index(data, []byte("TIT2"))
+		if enc := Encoding(data[i+frameLength]); enc != test.out {
+			t.Errorf("Policy %d wrote %q as %s, expected %s", test.policy, test.text, enc, test.out)
+		}
+	}
+}
+
 func TestTimeParsing(t *testing.T) {
 	tests := []struct {
 		in  string
@@ -128,6 +237,259 @@ func TestUserFrameNameParsing(t *testing.T) {
 	}
 }
 
+func TestUpgradeFrameFlags(t *testing.T) {
+	tests := []struct {
+		in, out FrameFlags
+	}{
+		{0x8000, flagDiscardOnTagAlteration},
+		{0x4000, flagDiscardOnFileAlteration},
+		{0x2000, flagReadOnly},
+		{0x0080, flagCompressed | flagDataLengthIndicator},
+		{0x0040, flagEncrypted},
+		{0x0020, flagGrouped},
+		{0xE0E0, 0x704D},
+	}
+
+	for _, test := range tests {
+		if res := upgradeFrameFlags(test.in); res != test.out {
+			t.Errorf("upgradeFrameFlags(%#04x) = %#04x, expected %#04x", test.in, res, test.out)
+		}
+	}
+}
+
+func TestUpgradeFlaggedFrame(t *testing.T) {
+	// A compressed, encrypted and grouped v2.3 frame with a
+	// decompressed size of 300000 bytes, encryption method 0x80 and
+	// group 0x90.
+	body := []byte("\x00\x04\x93\xE0\x80\x90data")
+	frame := append([]byte("TIT2\x00\x00\x00\x0A\x00\xE0"), body...)
+	data := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x14"), frame...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tag.Frames["TIT2"][0].Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := rawFrame("TIT2", flagCompressed|flagEncrypted|flagGrouped|flagDataLengthIndicator,
+		[]byte("\x90\x80\x00\x12\x27\x60data"))
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Upgraded frame is %q, expected %q", buf.Bytes(), expected)
+	}
+}
+
+func TestSynchsafeInt(t *testing.T) {
+	for _, i := range []int{0, 127, 128, 1 << 17, 300000, 1 << 21, 5<<21 | 1<<14 | 3, 1<<28 - 1} {
+		b := intToBytes(synchsafeInt(i))
+		if res := desynchsafeInt([4]byte{b[0], b[1], b[2], b[3]}); res != i {
+			t.Errorf("%d encoded as %x decodes to %d", i, b, res)
+		}
+	}
+}
+
+func TestLargeFrameRoundTrip(t *testing.T) {
+	// Sizes of 2 MB and more use the most significant byte of the
+	// synchsafe size.
+	tag := NewTag()
+	tag.Frames["PRIV"] = []Frame{PrivateFrame{FrameHeader: FrameHeader{id: "PRIV"}, Owner: []byte("owner"), Data: make([]byte, 5<<21+300000)}}
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := parsed.Frames["PRIV"]
+	if len(frames) != 1 || len(frames[0].(PrivateFrame).Data) != 5<<21+300000 {
+		t.Fatal("Large frame didn't survive encoding")
+	}
+}
+
+func TestFrameFlagsPreserved(t *testing.T) {
+	tag := NewTag()
+	header := NewFrameHeader("TIT2", 0)
+	header.SetPreserveFileAlteration(false)
+	tag.Frames["TIT2"] = []Frame{TextInformationFrame{FrameHeader: header, Text: "Old"}}
+
+	tag.SetTitle("New")
+	frame := tag.Frames["TIT2"][0].(TextInformationFrame)
+	if frame.Text != "New" || frame.Flags() != flagDiscardOnFileAlteration {
+		t.Fatalf("Unexpected frame after setting title: %#v", frame)
+	}
+
+	frame.SetReadOnly(true)
+	tag.Frames["TIT2"][0] = frame
+	tag.SetTitle("Newer")
+	if tag.Title() != "New" {
+		t.Fatalf("Read-only frame was modified: %q", tag.Title())
+	}
+}
+
+func TestAlterationDiscard(t *testing.T) {
+	discard := NewFrameHeader("XYZW", 0)
+	discard.SetPreserveTagAlteration(false)
+	known := NewFrameHeader("TIT2", 0)
+	known.SetPreserveTagAlteration(false)
+	known.SetPreserveFileAlteration(false)
+
+	tag := NewTag()
+	tag.Frames["XYZW"] = []Frame{UnsupportedFrame{FrameHeader: discard, Data: []byte{1}}}
+	tag.Frames["ABCD"] = []Frame{UnsupportedFrame{FrameHeader: NewFrameHeader("ABCD", 0), Data: []byte{1}}}
+	tag.Frames["TIT2"] = []Frame{TextInformationFrame{FrameHeader: known, Text: "Title"}}
+
+	if err := tag.Encode(new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if tag.HasFrame("XYZW") || !tag.HasFrame("ABCD") || !tag.HasFrame("TIT2") {
+		t.Fatalf("Wrong frames discarded on tag alteration: %v", tag.Frames)
+	}
+
+	fileDiscard := NewFrameHeader("XYZV", 0)
+	fileDiscard.SetPreserveFileAlteration(false)
+	tag.Frames["XYZV"] = []Frame{UnsupportedFrame{FrameHeader: fileDiscard, Data: []byte{1}}}
+	tag.RemoveFileAlterationFrames()
+	if tag.HasFrame("XYZV") || !tag.HasFrame("ABCD") || !tag.HasFrame("TIT2") {
+		t.Fatalf("Wrong frames discarded on file alteration: %v", tag.Frames)
+	}
+}
+
+// rawFrame builds the bytes of a v2.4 frame.
+func rawFrame(id string, flags FrameFlags, body []byte) []byte {
+	header := FrameHeader{id: FrameType(id), flags: flags}
+	return append(header.Serialize(len(body)), body...)
+}
+
+func TestLosslessSave(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("TPE1", 0, []byte("\x00Artist\x00")),
+		rawFrame("TSIZ", 0, []byte("\x001234")),
+		rawFrame("TALB", flagDiscardOnFileAlteration, []byte("\x01\xFF\xFEA\x00")),
+		rawFrame("TIT2", 0, []byte("\x00Title")),
+	}
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 100)...)
+	audio := bytes.Repeat([]byte{0xAA}, 100)
+	data := append(append(generateHeader(len(body), 0), body...), audio...)
+	name := writeTestFile(t, data)
+
+	f, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Lossless = true
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, data) {
+		t.Fatal("Saving an unmodified tag in lossless mode changed the file")
+	}
+
+	f.SetTitle("New title")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	saved, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged := bytes.Join(frames[:3], nil)
+	if !bytes.HasPrefix(saved[tagHeaderSize:], unchanged) {
+		t.Fatal("Unmodified frames weren't written verbatim")
+	}
+	tit2 := TextInformationFrame{FrameHeader: FrameHeader{id: "TIT2"}, Text: "New title"}
+	buf := new(bytes.Buffer)
+	tit2.Encode(buf)
+	if !bytes.HasPrefix(saved[tagHeaderSize+len(unchanged):], buf.Bytes()) {
+		t.Fatal("Modified frame wasn't re-encoded")
+	}
+	if !bytes.HasSuffix(saved, audio) {
+		t.Fatal("Audio data was modified")
+	}
+}
+
+func TestLosslessModified(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("TIT2", 0, []byte("\x00Title")),
+		rawFrame("TALB", 0, []byte("\x00Album")),
+		rawFrame("TPE1", 0, []byte("\x00Artist")),
+		rawFrame("COMM", 0, []byte("\x00engdesc\x00Comment")),
+	}
+	body := bytes.Join(frames, nil)
+	data := append(generateHeader(len(body), 0), body...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.Lossless = true
+
+	title := tag.Frames["TIT2"][0].(TextInformationFrame)
+	title.Text = "New title"
+	tag.Frames["TIT2"][0] = title
+
+	album := tag.Frames["TALB"][0].(TextInformationFrame)
+	album.Text = "New album"
+	tag.SetFrame(album)
+
+	artist := tag.Frames["TPE1"][0].(TextInformationFrame)
+	artist.SetReadOnly(true)
+	tag.Frames["TPE1"][0] = artist
+
+	if raw := tag.originalBytes(tag.Frames["COMM"][0]); !bytes.Equal(raw, frames[3]) {
+		t.Fatalf("Unmodified frame isn't written verbatim: %q", raw)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title() != "New title" || parsed.Album() != "New album" {
+		t.Errorf("Modified frames weren't re-encoded: %q, %q", parsed.Title(), parsed.Album())
+	}
+	if !frameFlags(parsed.Frames["TPE1"][0]).ReadOnly() {
+		t.Error("Changed flags weren't written")
+	}
+}
+
+func TestEmptyFrames(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("PRIV", 0, nil),
+		rawFrame("MCDI", 0, nil),
+		rawFrame("XXXX", 0, nil),
+		rawFrame("TIT2", 0, []byte("\x00Title")),
+	}
+	body := bytes.Join(frames, nil)
+	data := append(generateHeader(len(body), 0), body...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []FrameType{"PRIV", "MCDI", "XXXX"} {
+		if len(tag.Frames[id]) != 1 {
+			t.Errorf("Empty %s frame wasn't read", id)
+		}
+	}
+	if tag.Title() != "Title" {
+		t.Errorf("Frame following empty frames wasn't read, title is %q", tag.Title())
+	}
+}
+
 func BenchmarkISO88591ToUTF8(b *testing.B) {
 	b.SetBytes(int64(len(ISOTestString)))
 	for i := 0; i < b.N; i++ {
@@ -138,7 +500,7 @@ func BenchmarkISO88591ToUTF8(b *testing.B) {
 func BenchmarkUTF8ToISO88591(b *testing.B) {
 	b.SetBytes(int64(len(UTF8TestString)))
 	for i := 0; i < b.N; i++ {
-		_ = utf8ToISO88591(UTF8TestString)
+		_, _ = utf8ToISO88591(UTF8TestString, 0)
 	}
 }
 
@@ -149,10 +511,16 @@ func BenchmarkUTF16ToUTF8(b *testing.B) {
 	}
 }
 
-func ExampleTag_GetTextFrame_text(t *Tag) {
-	t.GetTextFrame("TIT2") // Same as f.Title()
+func ExampleTag_GetTextFrame_text() {
+	t := NewTag()
+	t.SetTitle("Title")
+	fmt.Println(t.GetTextFrame("TIT2")) // Same as t.Title()
+	// Output: Title
 }
 
-func ExampleTag_GetTextFrame_user(t *Tag) {
-	t.GetTextFrame("TXXX:MusicBrainz Album Artist Id")
+func ExampleTag_GetTextFrame_user() {
+	t := NewTag()
+	t.SetTextFrame("TXXX:MusicBrainz Album Artist Id", "89ad4ac3-39f7-470e-963a-56509c546377")
+	fmt.Println(t.GetTextFrame("TXXX:MusicBrainz Album Artist Id"))
+	// Output: 89ad4ac3-39f7-470e-963a-56509c546377
 }
@@ -0,0 +1,115 @@
+package id3
+
+import "io"
+
+// A Layout describes where the parts of a file are located, as byte
+// offsets from its beginning. Each range includes its start and
+// excludes its end.
+type Layout struct {
+	// TagStart and TagEnd delimit the tag at the beginning of the
+	// file, including its header, padding and footer. Both are 0 if
+	// there is no such tag.
+	TagStart, TagEnd int64
+	// AudioStart and AudioEnd delimit the audio data.
+	AudioStart, AudioEnd int64
+	// AppendedStart and AppendedEnd delimit the tag at the end of the
+	// file, including its header and footer. Both equal AudioEnd if
+	// there is no such tag. Data following AppendedEnd, usually an
+	// ID3v1 tag, is neither audio data nor part of an ID3v2 tag.
+	AppendedStart, AppendedEnd int64
+	// Size is the size of the file.
+	Size int64
+}
+
+// Layout returns the layout of the file as it was read or last saved.
+func (f *File) Layout() Layout {
+	return Layout{
+		TagEnd:        f.audioStart,
+		AudioStart:    f.audioStart,
+		AudioEnd:      f.audioEnd,
+		AppendedStart: f.audioEnd,
+		AppendedEnd:   f.trailerStart,
+		Size:          f.fileSize,
+	}
+}
+
+// ParseAt parses the tag of a file of the given size, like Open, and
+// returns the layout of the file. Unlike Parse, it finds tags
+// appended to the end of the file and follows SEEK frames, and it
+// doesn't require the file to be stored on disk. A file without a tag
+// isn't an error; the returned tag is empty then.
+func ParseAt(r io.ReaderAt, size int64) (*Tag, Layout, error) {
+	return parseAt(r, size, nil)
+}
+
+// ParseAtWithOptions parses a tag like ParseAt, according to opts.
+func ParseAtWithOptions(r io.ReaderAt, size int64, opts Options) (*Tag, Layout, error) {
+	return parseAt(r, size, &opts)
+}
+
+// ParseReadSeeker parses a tag like ParseAt, determining the size of
+// the file by seeking to its end. If rs implements io.ReaderAt, it is
+// used for reading instead of seeking.
+func ParseReadSeeker(rs io.ReadSeeker) (*Tag, Layout, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, Layout{}, err
+	}
+
+	r, ok := rs.(io.ReaderAt)
+	if !ok {
+		r = readSeekerAt{rs}
+	}
+
+	return parseAt(r, size, nil)
+}
+
+func parseAt(r io.ReaderAt, size int64, opts *Options) (*Tag, Layout, error) {
+	tag, err := parse(io.NewSectionReader(r, 0, size), opts)
+	if err != nil {
+		if _, ok := err.(notATagHeader); !ok {
+			return nil, Layout{}, err
+		}
+	}
+
+	f := &File{src: r, fileSize: size, Tag: tag}
+	err = f.layout(f.HasTag())
+	if err != nil {
+		return nil, Layout{}, err
+	}
+
+	err = f.mergeTags()
+	if err != nil {
+		return nil, Layout{}, err
+	}
+
+	return f.Tag, f.Layout(), nil
+}
+
+// readSeekerAt implements io.ReaderAt by seeking. It must not be used
+// concurrently.
+type readSeekerAt struct {
+	rs io.ReadSeeker
+}
+
+func (r readSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	_, err := r.rs.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes the underlying reader, if it is an io.Closer, so that
+// File.Close works for files that are read by seeking.
+func (r readSeekerAt) Close() error {
+	if c, ok := r.rs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestParseAt(t *testing.T) {
+	head := NewTag()
+	head.SetTitle("Title")
+	prepended := new(bytes.Buffer)
+	if err := head.Encode(prepended); err != nil {
+		t.Fatal(err)
+	}
+
+	tail := NewTag()
+	tail.SetAlbum("Album")
+	appended := new(bytes.Buffer)
+	if err := tail.EncodeAppended(appended); err != nil {
+		t.Fatal(err)
+	}
+
+	audio := bytes.Repeat([]byte{0xAA}, 1000)
+	id3v1 := append([]byte("TAG"), make([]byte, id3v1Size-3)...)
+	var data []byte
+	for _, part := range [][]byte{prepended.Bytes(), audio, appended.Bytes(), id3v1} {
+		data = append(data, part...)
+	}
+
+	audioStart := int64(prepended.Len())
+	audioEnd := audioStart + int64(len(audio))
+	want := Layout{
+		TagEnd:        audioStart,
+		AudioStart:    audioStart,
+		AudioEnd:      audioEnd,
+		AppendedStart: audioEnd,
+		AppendedEnd:   audioEnd + int64(appended.Len()),
+		Size:          int64(len(data)),
+	}
+
+	// Hide the ReadAt method so that ParseReadSeeker has to seek.
+	seeker := struct{ io.ReadSeeker }{bytes.NewReader(data)}
+	for _, parse := range []func() (*Tag, Layout, error){
+		func() (*Tag, Layout, error) { return ParseAt(bytes.NewReader(data), int64(len(data))) },
+		func() (*Tag, Layout, error) { return ParseReadSeeker(seeker) },
+	} {
+		tag, layout, err := parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layout != want {
+			t.Fatalf("Layout is %+v, expected %+v", layout, want)
+		}
+		if tag.Title() != "Title" || tag.Album() != "Album" {
+			t.Fatalf("Unexpected tag: %q, %q", tag.Title(), tag.Album())
+		}
+	}
+
+	tag, layout, err := ParseAt(bytes.NewReader(audio), int64(len(audio)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 0 || layout.AudioStart != 0 || layout.AudioEnd != int64(len(audio)) {
+		t.Fatalf("Unexpected result for file without tag: %v, %+v", tag.Frames, layout)
+	}
+}
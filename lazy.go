@@ -0,0 +1,218 @@
+package id3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrFiltered is returned when saving a file whose tag was parsed
+// with Options.Filter and is missing the frames that were skipped.
+var ErrFiltered = errors.New("id3: cannot save tag with filtered frames")
+
+// eagerFrames are frames that are decoded even when parsing lazily,
+// besides text and URL frames, because getters or the library itself
+// use them, and they are usually small.
+var eagerFrames = map[FrameType]bool{
+	"ASPI": true,
+	"COMM": true,
+	"MLLT": true,
+	"POSS": true,
+	"SEEK": true,
+	"UFID": true,
+	"USLT": true,
+}
+
+// isLazy reports whether lazy parsing defers decoding frames with the
+// given ID. Frames handled by a FrameCodec are always decoded.
+func isLazy(id FrameType) bool {
+	if id[0] == 'T' || id[0] == 'W' || eagerFrames[id] {
+		return false
+	}
+	_, ok := lookupFrameCodec(id)
+	return !ok
+}
+
+// A LazyFrame is a frame whose body hasn't been read or decoded yet.
+// Parsing with Options.Lazy stores large binary frames, like APIC and
+// GEOB, as LazyFrames. Decode returns the actual frame, and
+// Tag.DecodeFrames replaces LazyFrames in a tag.
+//
+// A LazyFrame of a file reads its body from the file, which therefore
+// has to stay open until the frame is decoded.
+//
+// LazyFrames aren't subject to Options.Duplicates, and Tag.SetFrame
+// doesn't replace them.
+type LazyFrame struct {
+	FrameHeader
+	bodySize int                    // Size of the body
+	load     func() ([]byte, error) // Reads the raw frame
+	charset  CharsetDecoder
+	frame    Frame // The decoded frame, once decoded
+}
+
+// BodySize returns the size of the frame's body as stored in the tag.
+func (f *LazyFrame) BodySize() int {
+	return f.bodySize
+}
+
+// Decode reads and decodes the frame. The result is cached, so that
+// only the first call reads the frame.
+func (f *LazyFrame) Decode() (Frame, error) {
+	if f.frame != nil {
+		return f.frame, nil
+	}
+
+	raw, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var version Version = 0x0400
+	if f.legacy {
+		version = 0x0300
+	}
+	frame, err := readFrame(bytes.NewReader(raw), version)
+	if err != nil {
+		return nil, err
+	}
+	if f.charset != nil {
+		if res, _, err := recodeFrame(frame, f.charset); err == nil {
+			frame = res
+		}
+	}
+
+	f.frame = frame
+	return frame, nil
+}
+
+// Value returns the value of the decoded frame, or an empty string if
+// it cannot be decoded.
+func (f *LazyFrame) Value() string {
+	frame, err := f.Decode()
+	if err != nil {
+		return ""
+	}
+
+	return frame.Value()
+}
+
+func (f *LazyFrame) Size() int {
+	return f.size(encoder{})
+}
+
+func (f *LazyFrame) Encode(w io.Writer) error {
+	return f.encode(w, encoder{})
+}
+
+func (f *LazyFrame) size(e encoder) int {
+	frame, err := f.Decode()
+	if err != nil {
+		// Encoding will fail anyway.
+		return frameLength + f.bodySize
+	}
+
+	return frameSize(frame, e)
+}
+
+func (f *LazyFrame) encode(w io.Writer, e encoder) error {
+	frame, err := f.Decode()
+	if err != nil {
+		return err
+	}
+
+	return encodeFrame(w, frame, e)
+}
+
+// DecodeFrames replaces all LazyFrames in the tag with their decoded
+// frames. It stops at the first frame that cannot be decoded.
+func (t *Tag) DecodeFrames() error {
+	for _, frames := range t.Frames {
+		for i, frame := range frames {
+			lazy, ok := frame.(*LazyFrame)
+			if !ok {
+				continue
+			}
+
+			decoded, err := lazy.Decode()
+			if err != nil {
+				return err
+			}
+			frames[i] = decoded
+		}
+	}
+
+	return nil
+}
+
+// lazyFrame creates a LazyFrame for a frame of frameSize bytes, whose
+// header has been read from r. If src isn't nil, the body is skipped
+// and later read from src at offset, the beginning of the frame.
+// Otherwise it is read into memory now.
+func lazyFrame(r *io.LimitedReader, header FrameHeader, frameSize int, src io.ReaderAt, offset int64, charset CharsetDecoder) (*LazyFrame, error) {
+	frame := &LazyFrame{FrameHeader: header, bodySize: frameSize, charset: charset}
+	// The original bytes belong to the decoded frame.
+	frame.raw = nil
+	if src == nil {
+		raw, err := readFrameData(r, header, frameSize)
+		if err != nil {
+			return nil, err
+		}
+		frame.load = func() ([]byte, error) { return raw, nil }
+		return frame, nil
+	}
+
+	err := skip(r, int64(frameSize))
+	if err != nil {
+		return nil, err
+	}
+	frame.load = func() ([]byte, error) {
+		raw := make([]byte, frameLength+frameSize)
+		n, err := src.ReadAt(raw, offset)
+		if err == io.EOF && n < len(raw) {
+			err = io.ErrUnexpectedEOF
+		} else if err == io.EOF {
+			err = nil
+		}
+		return raw, err
+	}
+	return frame, nil
+}
+
+// skip skips n bytes of r, seeking if possible.
+func skip(r *io.LimitedReader, n int64) error {
+	if n > r.N {
+		return io.ErrUnexpectedEOF
+	}
+
+	if s, ok := r.R.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		r.N -= n
+		return nil
+	}
+
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// FrameFilter returns a filter for Options.Filter that accepts frames
+// whose ID starts with one of the given prefixes. For example,
+// FrameFilter("T", "COMM") only reads text frames and comments.
+func FrameFilter(prefixes ...string) func(id FrameType) bool {
+	return func(id FrameType) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(string(id), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
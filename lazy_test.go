@@ -0,0 +1,113 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func lazyTestData(t *testing.T) ([]byte, []byte) {
+	picture := bytes.Repeat([]byte{0xFF}, 5000)
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.Frames["APIC"] = []Frame{PictureFrame{
+		FrameHeader: FrameHeader{id: "APIC"},
+		MIMEType:    "image/jpeg",
+		Description: "Cover",
+		Data:        picture,
+	}}
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(bytes.Repeat([]byte{0xAA}, 1000))
+
+	return buf.Bytes(), picture
+}
+
+func TestLazyFrames(t *testing.T) {
+	data, picture := lazyTestData(t)
+	opts := DefaultOptions()
+	opts.Lazy = true
+
+	for _, seekable := range []bool{false, true} {
+		var tag *Tag
+		var err error
+		if seekable {
+			tag, _, err = ParseAtWithOptions(bytes.NewReader(data), int64(len(data)), opts)
+		} else {
+			tag, err = ParseWithOptions(bytes.NewBuffer(data), opts)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Title() != "Title" {
+			t.Fatalf("Title is %q", tag.Title())
+		}
+
+		lazy, ok := tag.Frames["APIC"][0].(*LazyFrame)
+		if !ok {
+			t.Fatalf("APIC is %T, expected *LazyFrame", tag.Frames["APIC"][0])
+		}
+		if seekable && lazy.frame != nil {
+			t.Fatal("Frame was decoded while parsing")
+		}
+
+		buf := new(bytes.Buffer)
+		if err := tag.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := tag.DecodeFrames(); err != nil {
+			t.Fatal(err)
+		}
+		pic, ok := tag.Frames["APIC"][0].(PictureFrame)
+		if !ok || pic.Description != "Cover" || !bytes.Equal(pic.Data, picture) {
+			t.Fatalf("Unexpected decoded frame %T", tag.Frames["APIC"][0])
+		}
+		if !bytes.HasPrefix(data, buf.Bytes()[:tagHeaderSize+tag.framesSize()]) {
+			t.Fatal("Encoding lazy frames changed the tag")
+		}
+	}
+}
+
+func TestLazyFramesSave(t *testing.T) {
+	data, picture := lazyTestData(t)
+	fsys := memFS{"test.mp3": &data}
+	opts := DefaultOptions()
+	opts.Lazy = true
+	f, err := OpenFSWithOptions(fsys, "test.mp3", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetTextFrame("TIT3", string(bytes.Repeat([]byte("x"), 2000)))
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tag, err := Parse(bytes.NewReader(*fsys["test.mp3"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pic, ok := tag.Frames["APIC"][0].(PictureFrame); !ok || !bytes.Equal(pic.Data, picture) {
+		t.Fatal("Picture wasn't preserved")
+	}
+}
+
+func TestFrameFilter(t *testing.T) {
+	data, _ := lazyTestData(t)
+	fsys := memFS{"test.mp3": &data}
+	opts := DefaultOptions()
+	opts.Filter = FrameFilter("T")
+	f, err := OpenFSWithOptions(fsys, "test.mp3", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Title() != "Title" || f.HasFrame("APIC") {
+		t.Fatalf("Unexpected frames %v", f.Frames)
+	}
+	if err := f.Save(); err != ErrFiltered {
+		t.Fatalf("Expected ErrFiltered, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Limits restrict the resources that parsing a tag may use, to
+// protect against hostile input. Limits are checked before anything
+// is allocated. Zero values mean no limit.
+//
+// Independently of the limits, frames larger than the remainder of
+// their tag are rejected before their body is allocated. When reading
+// from a source of known size, like a file or an io.SectionReader, the
+// tag cannot extend beyond the end of the source, either. Otherwise,
+// frame bodies are read in chunks, so that memory is only allocated
+// for data that actually exists.
+type Limits struct {
+	// TagSize is the maximum size of a tag, excluding its header.
+	TagSize int
+	// FrameSize is the maximum size of a frame's body.
+	FrameSize int
+	// Frames is the maximum number of frames in a tag, including
+	// skipped ones.
+	Frames int
+}
+
+// defaultLimits are the limits of DefaultOptions. They allow large
+// pictures, while keeping the memory hostile input can claim in
+// check.
+var defaultLimits = Limits{
+	TagSize:   64 << 20,
+	FrameSize: 32 << 20,
+	Frames:    4096,
+}
+
+// LimitKind specifies which limit was exceeded.
+type LimitKind int
+
+const (
+	LimitTagSize LimitKind = iota
+	LimitFrameSize
+	LimitFrames
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitTagSize:
+		return "tag size"
+	case LimitFrameSize:
+		return "frame size"
+	case LimitFrames:
+		return "frame count"
+	default:
+		return fmt.Sprintf("Unknown limit %d", int(k))
+	}
+}
+
+// A LimitError is returned when a tag exceeds one of the Limits.
+type LimitError struct {
+	Kind  LimitKind
+	Frame FrameType // The frame exceeding the limit, if any
+	Value int64
+	Max   int64
+}
+
+func (e LimitError) Error() string {
+	if e.Frame != "" {
+		return fmt.Sprintf("id3: %s of %s frame is %d, exceeding the limit of %d", e.Kind, e.Frame, e.Value, e.Max)
+	}
+	return fmt.Sprintf("id3: %s is %d, exceeding the limit of %d", e.Kind, e.Value, e.Max)
+}
+
+// checkTag checks the size of a tag.
+func (l Limits) checkTag(header TagHeader) error {
+	if l.TagSize > 0 && header.Size > l.TagSize {
+		return LimitError{Kind: LimitTagSize, Value: int64(header.Size), Max: int64(l.TagSize)}
+	}
+
+	return nil
+}
+
+// newTagReader returns a reader for the remainder of a tag of the given
+// size, which r is positioned at, excluding its header. If r knows its
+// size, the tag is truncated to the end of r, so that frames claiming
+// to extend beyond the end aren't allocated. avail is the number of
+// bytes of r left before the tag header, or -1 if it is unknown.
+func newTagReader(r io.Reader, size int, avail int64) *io.LimitedReader {
+	n := int64(size)
+	if avail >= 0 && avail-tagHeaderSize < n {
+		n = avail - tagHeaderSize
+		if n < 0 {
+			n = 0
+		}
+	}
+
+	return &io.LimitedReader{R: r, N: n}
+}
+
+// available returns the number of bytes left in r, or -1 if r doesn't
+// know its size, like bytes.Reader and io.SectionReader do.
+func available(r io.Reader) int64 {
+	s, ok := r.(interface {
+		io.Seeker
+		Size() int64
+	})
+	if !ok {
+		return -1
+	}
+
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	return s.Size() - pos
+}
+
+// checkFrame checks the n-th frame of a tag, whose body has the given
+// size, and of whose tag remaining bytes are left.
+func (l Limits) checkFrame(header FrameHeader, size int, n int, remaining int64) error {
+	if l.Frames > 0 && n > l.Frames {
+		return LimitError{Kind: LimitFrames, Value: int64(n), Max: int64(l.Frames)}
+	}
+	if l.FrameSize > 0 && size > l.FrameSize {
+		return LimitError{Kind: LimitFrameSize, Frame: header.id, Value: int64(size), Max: int64(l.FrameSize)}
+	}
+	if int64(size) > remaining {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// readChunkSize is the size of the chunks in which readFrameData reads
+// large frames.
+const readChunkSize = 64 << 10
+
+// readFrameData reads the body of a frame of frameSize bytes from r
+// and returns it preceded by the raw header. Large bodies are read in
+// chunks, so that a frame claiming to be larger than the remaining
+// input doesn't allocate its claimed size.
+func readFrameData(r io.Reader, header FrameHeader, frameSize int) ([]byte, error) {
+	if frameSize <= readChunkSize {
+		raw := make([]byte, frameLength+frameSize)
+		copy(raw, header.raw)
+		_, err := io.ReadFull(r, raw[frameLength:])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return raw, err
+	}
+
+	raw := make([]byte, frameLength, frameLength+readChunkSize)
+	copy(raw, header.raw)
+	buf := bytes.NewBuffer(raw)
+	_, err := io.CopyN(buf, r, int64(frameSize))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
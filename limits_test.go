@@ -0,0 +1,132 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("TIT2", 0, []byte("\x00Title")),
+		rawFrame("TALB", 0, []byte("\x00Album")),
+		rawFrame("PRIV", 0, append([]byte("owner\x00"), make([]byte, 100)...)),
+	}
+	body := bytes.Join(frames, nil)
+	data := append(generateHeader(len(body), 0), body...)
+
+	for _, tt := range []struct {
+		limits Limits
+		err    error
+	}{
+		{Limits{}, nil},
+		{Limits{TagSize: len(body)}, nil},
+		{Limits{TagSize: len(body) - 1}, LimitError{Kind: LimitTagSize, Value: int64(len(body)), Max: int64(len(body) - 1)}},
+		{Limits{FrameSize: 50}, LimitError{Kind: LimitFrameSize, Frame: "PRIV", Value: 106, Max: 50}},
+		{Limits{Frames: 2}, LimitError{Kind: LimitFrames, Value: 3, Max: 2}},
+	} {
+		opts := DefaultOptions()
+		opts.Limits = tt.limits
+		_, err := ParseWithOptions(bytes.NewReader(data), opts)
+		if err != tt.err {
+			t.Errorf("Limits %+v: got error %v, expected %v", tt.limits, err, tt.err)
+		}
+	}
+}
+
+func TestOversizedFrame(t *testing.T) {
+	// The frame claims to be 30 MB large, in a tag of 20 bytes.
+	body := append(rawFrame("APIC", 0, nil), make([]byte, 10)...)
+	copy(body[4:8], intToBytes(synchsafeInt(30<<20)))
+	data := append(generateHeader(len(body), 0), body...)
+
+	_, err := Parse(bytes.NewReader(data))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestTruncatedTag(t *testing.T) {
+	// The tag claims to be 32 MB and the frame 16 MB large, in an
+	// input of 20 bytes.
+	data := []byte("ID3\x04\x00\x00\x10\x00\x00\x00APIC\x08\x00\x00\x00\x00\x00")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, _, err := ParseAt(bytes.NewReader(data), int64(len(data)))
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("Parsing %d bytes allocated %d bytes", len(data), alloc)
+	}
+}
+
+func TestUnsizedSource(t *testing.T) {
+	// The tag claims to be 256 MB and the frame 128 MB large, in an
+	// input of 30 bytes whose size the parser cannot determine.
+	data := []byte("ID3\x04\x00\x00\x7F\x7F\x7F\x7FTIT2\x40\x00\x00\x00\x00\x00\x00Title\x00\x00\x00\x00\x00")
+
+	_, err := Parse(io.MultiReader(bytes.NewReader(data)))
+	want := LimitError{Kind: LimitTagSize, Value: 0x0FFFFFFF, Max: 64 << 20}
+	if err != want {
+		t.Fatalf("Expected %v, got %v", want, err)
+	}
+
+	opts := DefaultOptions()
+	opts.Limits = Limits{}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = ParseWithOptions(io.MultiReader(bytes.NewReader(data)), opts)
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("Parsing %d bytes allocated %d bytes", len(data), alloc)
+	}
+}
+
+func TestCompressedFrame(t *testing.T) {
+	raw := rawFrame("TIT2", flagCompressed, []byte("compressed"))
+	data := append(generateHeader(len(raw), 0), raw...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, ok := tag.Frames["TIT2"][0].(UnsupportedFrame)
+	if !ok || string(frame.Data) != "compressed" {
+		t.Fatalf("Unexpected frame %#v", tag.Frames["TIT2"][0])
+	}
+}
+
+func TestFlaggedFrameAccessors(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("TXXX", flagCompressed, []byte("\x00desc\x00compressed")),
+		rawFrame("TXXX", 0, []byte("\x00other\x00text")),
+		rawFrame("COMM", flagGrouped, []byte("\x01\x00eng\x00grouped")),
+		rawFrame("COMM", flagEncrypted, []byte("encrypted")),
+	}
+	body := bytes.Join(frames, nil)
+	data := append(generateHeader(len(body), 0), body...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tag.GetTextFrame("TXXX:desc"); got != "" {
+		t.Errorf("GetTextFrame(TXXX:desc) = %q, want empty", got)
+	}
+	if got := tag.GetTextFrame("TXXX:other"); got != "text" {
+		t.Errorf("GetTextFrame(TXXX:other) = %q, want %q", got, "text")
+	}
+	if got := tag.UserTextFrames(); len(got) != 1 || got[0].Description != "other" {
+		t.Errorf("Unexpected user text frames %#v", got)
+	}
+	if got := tag.Comments(); len(got) != 0 {
+		t.Errorf("Unexpected comments %#v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package id3
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// Bitrates in kbit/s, indexed by [version][layer][bitrate index].
+// Version 0 is MPEG-1, version 1 is MPEG-2 and MPEG-2.5. Layer 0 is
+// layer I.
+var mpegBitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// Sample rates in Hz, indexed by the version bits of the header.
+var mpegSampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+// mpegFrame describes a single MPEG audio frame found while scanning
+// the audio data.
+type mpegFrame struct {
+	offset int64         // Offset relative to the beginning of the audio
+	size   int           // Size of the frame in bytes, including the header
+	start  time.Duration // Playback position at which the frame starts
+}
+
+// parseMPEGHeader parses the 4 byte header of an MPEG audio frame. It
+// returns the size of the frame in bytes and its duration. ok will be
+// false if b isn't a valid header.
+func parseMPEGHeader(b []byte) (size int, duration time.Duration, ok bool) {
+	if b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return 0, 0, false
+	}
+
+	version := (b[1] >> 3) & 3
+	layer := (b[1] >> 1) & 3
+	bitrateIndex := b[2] >> 4
+	rateIndex := (b[2] >> 2) & 3
+	padding := int((b[2] >> 1) & 1)
+
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return 0, 0, false
+	}
+
+	// Layer bits are 3 for layer I, 2 for layer II and 1 for layer
+	// III.
+	layerIndex := 3 - int(layer)
+	versionIndex := 0
+	if version != 3 {
+		versionIndex = 1
+	}
+
+	bitrate := mpegBitrates[versionIndex][layerIndex][bitrateIndex] * 1000
+	rate := mpegSampleRates[version][rateIndex]
+
+	var samples int
+	switch {
+	case layerIndex == 0:
+		samples = 384
+		size = (12*bitrate/rate + padding) * 4
+	case layerIndex == 2 && versionIndex == 1:
+		samples = 576
+		size = 72*bitrate/rate + padding
+	default:
+		samples = 1152
+		size = 144*bitrate/rate + padding
+	}
+
+	return size, time.Duration(samples) * time.Second / time.Duration(rate), true
+}
+
+// scanMPEGFrames returns all MPEG audio frames that can be found in r,
+// together with the total duration of the audio. Data that doesn't
+// belong to a frame, like an ID3v1 tag, is skipped.
+func scanMPEGFrames(r io.Reader) ([]mpegFrame, time.Duration, error) {
+	var (
+		frames []mpegFrame
+		offset int64
+		start  time.Duration
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		header, err := br.Peek(4)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, err
+		}
+
+		size, duration, ok := parseMPEGHeader(header)
+		if !ok || size < 4 {
+			br.Discard(1)
+			offset++
+			continue
+		}
+
+		n, err := br.Discard(size)
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if n < size {
+			// Truncated last frame
+			break
+		}
+
+		frames = append(frames, mpegFrame{offset: offset, size: size, start: start})
+		offset += int64(size)
+		start += duration
+	}
+
+	return frames, start, nil
+}
+
+// findMPEGFrame returns the offset of the first complete MPEG audio
+// frame in r, or -1 if there is none.
+func findMPEGFrame(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	for offset := int64(0); ; offset++ {
+		header, err := br.Peek(4)
+		if err != nil {
+			if err == io.EOF {
+				return -1, nil
+			}
+			return 0, err
+		}
+
+		size, _, ok := parseMPEGHeader(header)
+		if ok && size >= 4 {
+			frame, err := br.Peek(size)
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				return 0, err
+			}
+			if len(frame) == size {
+				return offset, nil
+			}
+		}
+
+		br.Discard(1)
+	}
+}
@@ -0,0 +1,241 @@
+package id3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+)
+
+// A Logger receives structured diagnostic messages. args are
+// alternating keys and values, like "frame", "TIT2", as accepted by
+// log/slog; *slog.Logger implements Logger.
+//
+// Messages carry the following keys where they apply: "file" for the
+// path of the file, "frame" for the frame ID, "action" for what the
+// library did about the situation, and "error" for the underlying
+// error.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// Options configure how tags are read and written. Unlike the
+// package-level variables, which only serve as defaults, they allow
+// using different settings concurrently. Start from DefaultOptions
+// to get the package's defaults. Functions that don't take options
+// use DefaultOptions, read at the time of the call.
+type Options struct {
+	// Padding determines the padding of tags that are written anew.
+	Padding PaddingPolicy
+	// InMemoryThreshold is the size limit in bytes for in-memory
+	// buffers when a file is rewritten in place, see RewriteInPlace.
+	InMemoryThreshold int64
+	// TempDir is the directory for temporary files used for
+	// buffering. If empty, os.TempDir is used. Files that get
+	// replaced by Save are always written next to the original.
+	TempDir string
+	// Preserve determines which attributes Save keeps when replacing
+	// a file.
+	Preserve PreserveFlags
+	// If RewriteInPlace is true, Save overwrites a file in place when
+	// no temporary file can be created next to it, for example in a
+	// read-only directory. A crash during such a save destroys the
+	// file. Otherwise, Save fails in that case.
+	RewriteInPlace bool
+
+	// Encoding and ISO88591Replacement initialize the fields of the
+	// same names of parsed tags.
+	Encoding            EncodingPolicy
+	ISO88591Replacement rune
+
+	// Charset, if not nil, decodes text that frames claim to store as
+	// ISO-8859-1, like in ParseCharset.
+	Charset CharsetDecoder
+	// Duplicates determines how Parse handles duplicate frames.
+	Duplicates DuplicatePolicy
+	// If Lazy is true, Parse doesn't decode large binary frames, like
+	// pictures, and stores them as LazyFrames instead. When parsing
+	// files, their bodies aren't even read.
+	Lazy bool
+	// Filter, if not nil, selects the frames Parse reads. Other
+	// frames are skipped without being read into memory, and files
+	// with skipped frames cannot be saved. See FrameFilter.
+	Filter func(id FrameType) bool
+	// Limits restrict the resources parsing may use.
+	Limits Limits
+	// If Strict is true, Parse fails on duplicate frames, frames
+	// registered codecs cannot decode and text it cannot decode with
+	// Charset instead of working around them, and saving fails if
+	// Validate reports errors.
+	Strict bool
+
+	// Logger receives diagnostic messages. If nil, none are logged.
+	// Pass a *slog.Logger to route them into log/slog.
+	Logger Logger
+}
+
+// Debug logs a message to the standard logger of package log if l is
+// true.
+func (l LogFlag) Debug(msg string, args ...interface{}) {
+	l.log(slog.LevelDebug, msg, args)
+}
+
+// Warn logs a message to the standard logger of package log if l is
+// true.
+func (l LogFlag) Warn(msg string, args ...interface{}) {
+	l.log(slog.LevelWarn, msg, args)
+}
+
+func (l LogFlag) log(level slog.Level, msg string, args []interface{}) {
+	if !l {
+		return
+	}
+
+	h := slog.NewTextHandler(log.Writer(), &slog.HandlerOptions{Level: slog.LevelDebug})
+	slog.New(h).Log(context.Background(), level, msg, args...)
+}
+
+// fileLogger adds the path of a file to messages.
+type fileLogger struct {
+	Logger
+	path string
+}
+
+func (l fileLogger) Debug(msg string, args ...interface{}) {
+	l.Logger.Debug(msg, append([]interface{}{"file", l.path}, args...)...)
+}
+
+func (l fileLogger) Warn(msg string, args ...interface{}) {
+	l.Logger.Warn(msg, append([]interface{}{"file", l.path}, args...)...)
+}
+
+// DefaultOptions returns the default options. They reflect the values
+// of the package-level variables Padding, InMemoryThreshold and
+// Logging at the time of the call. Other settings only exist as
+// options: by default, Save preserves the mode and owner of replaced
+// files, Parse keeps the first of duplicate frames, and parsing is
+// subject to default Limits.
+func DefaultOptions() Options {
+	opts := Options{
+		Padding:           PaddingPolicy{Fixed: Padding},
+		InMemoryThreshold: InMemoryThreshold,
+		Preserve:          PreserveMode | PreserveOwner,
+		Duplicates:        KeepFirst,
+		Limits:            defaultLimits,
+	}
+	if Logging {
+		opts.Logger = Logging
+	}
+
+	return opts
+}
+
+// A DuplicateFrameError is returned by strict parsing if a tag
+// contains frames that may not coexist.
+type DuplicateFrameError struct {
+	ID FrameType
+}
+
+func (e DuplicateFrameError) Error() string {
+	return fmt.Sprintf("id3: duplicate %s frame", e.ID)
+}
+
+// A ValidationError is returned by strict saving if the tag has
+// issues of SeverityError.
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("id3: tag is invalid: %s (and %d more issues)", e.Issues[0], len(e.Issues)-1)
+}
+
+// options returns the tag's options, or the defaults if the tag
+// wasn't created with options.
+func (t *Tag) options() Options {
+	if t.opts != nil {
+		return *t.opts
+	}
+
+	return DefaultOptions()
+}
+
+// logger returns the tag's logger, which discards messages if
+// logging is disabled and adds the path of the tag's file, if known.
+func (t *Tag) logger() Logger {
+	return t.loggerFor(t.opts)
+}
+
+// loggerFor returns the logger of opts like logger, or the default
+// logger if opts is nil.
+func (t *Tag) loggerFor(opts *Options) Logger {
+	var l Logger = Logging
+	if opts != nil {
+		l = opts.Logger
+		if l == nil {
+			l = LogFlag(false)
+		}
+	}
+	if t.path != "" {
+		l = fileLogger{l, t.path}
+	}
+
+	return l
+}
+
+// checkStrict returns a ValidationError if opts are strict and the
+// tag has errors.
+func (t *Tag) checkStrict(opts Options) error {
+	if !opts.Strict {
+		return nil
+	}
+
+	var errs []Issue
+	for _, issue := range t.Validate() {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) > 0 {
+		return ValidationError{errs}
+	}
+
+	return nil
+}
+
+// NewTagWithOptions returns an empty tag that will be written
+// according to opts.
+func NewTagWithOptions(opts Options) *Tag {
+	t := NewTag()
+	t.setOptions(opts)
+	return t
+}
+
+func (t *Tag) setOptions(opts Options) {
+	t.opts = &opts
+	t.Padding = &opts.Padding
+	t.Encoding = opts.Encoding
+	t.ISO88591Replacement = opts.ISO88591Replacement
+}
+
+// ParseWithOptions parses a tag like Parse, according to opts. The
+// returned tag will be written according to opts, too.
+func ParseWithOptions(r io.Reader, opts Options) (*Tag, error) {
+	return parse(r, &opts)
+}
+
+// OpenWithOptions opens a file like Open, reading and saving it
+// according to opts.
+func OpenWithOptions(name string, opts Options) (*File, error) {
+	return open(name, &opts)
+}
+
+// SaveWithOptions saves the file like Save, according to opts instead
+// of the options the file was opened with, and with opts.Padding
+// instead of the tag's padding policy. Fields of the tag that options
+// initialize, like Encoding, are left unchanged.
+func (f *File) SaveWithOptions(opts Options) error {
+	return f.save(opts)
+}
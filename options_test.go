@@ -0,0 +1,174 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintln(append([]interface{}{msg}, args...)...))
+}
+
+func (l *testLogger) Warn(msg string, args ...interface{}) {
+	l.Debug(msg, args...)
+}
+
+func TestStrictOptions(t *testing.T) {
+	var body []byte
+	for _, frame := range [][]byte{
+		rawFrame("TIT2", 0, []byte("\x00One")),
+		rawFrame("TIT2", 0, []byte("\x00Two")),
+	} {
+		body = append(body, frame...)
+	}
+	data := append(generateHeader(len(body), 0), body...)
+
+	logger := new(testLogger)
+	opts := DefaultOptions()
+	opts.Logger = logger
+	tag, err := ParseWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Title() != "One" || len(logger.lines) != 1 {
+		t.Fatalf("Title is %q, logged %q", tag.Title(), logger.lines)
+	}
+
+	opts.Strict = true
+	_, err = ParseWithOptions(bytes.NewReader(data), opts)
+	if err != (DuplicateFrameError{"TIT2"}) {
+		t.Fatalf("Expected duplicate frame error, got %v", err)
+	}
+
+	tag = NewTagWithOptions(opts)
+	tag.SetTextFrame("TSRC", "invalid")
+	if err := tag.Encode(new(bytes.Buffer)); err == nil {
+		t.Fatal("Strict encoding of invalid tag succeeded")
+	} else if _, ok := err.(ValidationError); !ok {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+}
+
+func TestOptionsInitializeTag(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Padding = PaddingPolicy{Fixed: 7}
+	opts.Encoding = AlwaysUTF16
+
+	tag := NewTagWithOptions(opts)
+	tag.SetTitle("Title")
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if padding := int(parsed.Header.Size) - tag.framesSize(); padding != 7 {
+		t.Fatalf("Padding is %d bytes, expected 7", padding)
+	}
+	if raw := parsed.Frames["TIT2"][0].(TextInformationFrame).raw; raw[frameLength] != byte(utf16bom) {
+		t.Fatal("Title wasn't encoded as UTF-16")
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var body []byte
+	for _, frame := range [][]byte{
+		rawFrame("TIT2", 0, []byte("\x00One")),
+		rawFrame("TIT2", 0, []byte("\x00Two")),
+	} {
+		body = append(body, frame...)
+	}
+	name := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(name, append(generateHeader(len(body), 0), body...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	opts := DefaultOptions()
+	opts.Duplicates = KeepLast
+	opts.Logger = slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	f, err := OpenWithOptions(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var record struct {
+		Msg    string
+		File   string
+		Frame  string
+		Action string
+	}
+	if err := json.NewDecoder(buf).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Msg != "duplicate frame" || record.File != name || record.Frame != "TIT2" || record.Action != "kept last" {
+		t.Fatalf("Unexpected log record %+v", record)
+	}
+}
+
+func TestObsoleteFrameLogged(t *testing.T) {
+	logger := new(testLogger)
+	opts := DefaultOptions()
+	opts.Logger = logger
+	tag := NewTagWithOptions(opts)
+	tag.Frames["TSIZ"] = []Frame{TextInformationFrame{FrameHeader: FrameHeader{id: "TSIZ"}, Text: "1234"}}
+
+	if err := tag.Encode(new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.lines) != 1 || logger.lines[0] != "not writing obsolete frame frame TSIZ action skipped\n" {
+		t.Fatalf("Unexpected log messages %q", logger.lines)
+	}
+}
+
+func TestSaveWithOptions(t *testing.T) {
+	name := writeTestFile(t, make([]byte, 1000))
+	f, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.SetTitle("Title")
+
+	// Reading the file's settings while saving must not race.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = f.paddingPolicy()
+			_ = f.options()
+		}
+	}()
+
+	logger := new(testLogger)
+	opts := DefaultOptions()
+	opts.Logger = logger
+	opts.Padding = PaddingPolicy{Fixed: 10}
+	if err := f.SaveWithOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if padding := f.Header.Size - f.framesSize(); padding != 10 {
+		t.Errorf("Padding is %d bytes, expected 10", padding)
+	}
+	if len(logger.lines) == 0 {
+		t.Error("Nothing was logged to the logger of the options")
+	}
+	if f.opts != nil || f.Padding != nil {
+		t.Error("Options of the file were changed")
+	}
+}
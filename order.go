@@ -0,0 +1,149 @@
+package id3
+
+import (
+	"io"
+	"sort"
+)
+
+// A FrameOrder reports whether frames with ID a should be written
+// before frames with ID b. It has to describe a strict weak ordering,
+// like the less function of sort.Slice.
+type FrameOrder func(a, b FrameType) bool
+
+var frameRanks = map[FrameType]int{
+	"TIT2": -3,
+	"TPE1": -2,
+	"TALB": -1,
+	"APIC": 1,
+}
+
+// DefaultFrameOrder writes the title, artist and album first and
+// attached pictures last. All other frames are sorted by their ID.
+func DefaultFrameOrder(a, b FrameType) bool {
+	ra, rb := frameRanks[a], frameRanks[b]
+	if ra != rb {
+		return ra < rb
+	}
+
+	return a < b
+}
+
+// frameIDs returns the IDs of all frames in the order they should be
+// written in. IDs that were present when the tag was parsed keep their
+// original order, while new IDs get inserted according to the tag's
+// FrameOrder.
+func (t *Tag) frameIDs() []FrameType {
+	less := t.Order
+	if less == nil {
+		less = DefaultFrameOrder
+	}
+
+	var ids, added []FrameType
+	seen := make(map[FrameType]bool)
+	for _, id := range t.order {
+		if t.HasFrame(id) && !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+	for id := range t.Frames {
+		if !seen[id] {
+			added = append(added, id)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return less(added[i], added[j])
+	})
+
+	// Insert every new ID after the last ID that precedes it.
+	for _, id := range added {
+		i := 0
+		for j, other := range ids {
+			if less(other, id) {
+				i = j + 1
+			}
+		}
+		ids = append(ids, "")
+		copy(ids[i+1:], ids[i:])
+		ids[i] = id
+	}
+
+	return ids
+}
+
+// encodeFrames writes all frames in the order determined by
+// frameIDs, using the tag's encoding settings. In lossless mode,
+// unmodified frames are written using their original bytes.
+func (t *Tag) encodeFrames(w io.Writer, log Logger) error {
+	for _, id := range t.frameIDs() {
+		for _, frame := range t.Frames[id] {
+			var err error
+			if raw := t.originalBytes(frame); raw != nil {
+				_, err = w.Write(raw)
+			} else if f, ok := frame.(TextInformationFrame); ok && f.obsolete() {
+				log.Debug("not writing obsolete frame", "frame", id, "action", "skipped")
+			} else {
+				err = encodeFrame(w, frame, t.encoder())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// framesSize returns the size of all frames as written by
+// encodeFrames.
+func (t *Tag) framesSize() int {
+	size := 0
+	for _, frames := range t.Frames {
+		for _, frame := range frames {
+			if raw := t.originalBytes(frame); raw != nil {
+				size += len(raw)
+			} else {
+				size += frameSize(frame, t.encoder())
+			}
+		}
+	}
+
+	return size
+}
+
+// originalBytes returns the bytes a frame was read from, or nil if
+// the frame has been modified since.
+func (t *Tag) originalBytes(frame Frame) []byte {
+	if !t.Lossless {
+		return nil
+	}
+
+	if lazy, ok := frame.(*LazyFrame); ok {
+		decoded, err := lazy.Decode()
+		if err != nil {
+			return nil
+		}
+		frame = decoded
+	}
+
+	if f, ok := frame.(interface {
+		isLegacy() bool
+	}); ok && f.isLegacy() {
+		return nil
+	}
+
+	return sourceBytes(frame)
+}
+
+func (fm FramesMap) encode(w io.Writer, ids []FrameType) error {
+	for _, id := range ids {
+		for _, frame := range fm[id] {
+			err := frame.Encode(w)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package id3
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultFrameOrder(t *testing.T) {
+	tag := NewTag()
+	tag.Frames["APIC"] = []Frame{PictureFrame{FrameHeader: FrameHeader{id: "APIC"}}}
+	tag.SetMood("Happy")
+	tag.SetAlbum("Album")
+	tag.SetBPM(120)
+	tag.SetArtist("Artist")
+	tag.SetTitle("Title")
+
+	expected := []FrameType{"TIT2", "TPE1", "TALB", "TBPM", "TMOO", "APIC"}
+	if ids := tag.frameIDs(); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Frames ordered as %v, expected %v", ids, expected)
+	}
+}
+
+func TestPreserveFrameOrder(t *testing.T) {
+	var buf bytes.Buffer
+	for _, frame := range []Frame{
+		TextInformationFrame{FrameHeader: FrameHeader{id: "TMOO"}, Text: "Happy"},
+		TextInformationFrame{FrameHeader: FrameHeader{id: "TALB"}, Text: "Album"},
+		TextInformationFrame{FrameHeader: FrameHeader{id: "TBPM"}, Text: "120"},
+	} {
+		if err := frame.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := append(generateHeader(buf.Len(), 0), buf.Bytes()...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.SetTitle("Title")
+	tag.SetComposer("Composer")
+
+	expected := []FrameType{"TIT2", "TMOO", "TALB", "TBPM", "TCOM"}
+	if ids := tag.frameIDs(); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Frames ordered as %v, expected %v", ids, expected)
+	}
+
+	tag.Order = func(a, b FrameType) bool { return a > b }
+	tag.SetArtist("Artist")
+	expected = []FrameType{"TPE1", "TMOO", "TIT2", "TCOM", "TALB", "TBPM"}
+	if ids := tag.frameIDs(); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Frames ordered as %v, expected %v", ids, expected)
+	}
+}
@@ -0,0 +1,74 @@
+package id3
+
+// A PaddingPolicy determines how much padding follows the frames when
+// a tag is written anew. Padding allows later saves to grow the tag in
+// place instead of rewriting the whole file.
+type PaddingPolicy struct {
+	// Fixed is a constant amount of padding in bytes.
+	Fixed int
+	// Percent adds padding proportional to the size of the frames.
+	Percent int
+	// Block, if positive, rounds the size of the tag, including its
+	// header, up to a multiple of Block bytes.
+	Block int
+	// Min and Max limit the amount of padding. A Max of 0 means no
+	// limit. Rounding to Block takes precedence over Max.
+	Min, Max int
+	// Shrink allows reducing the padding a tag already has to the
+	// amount determined by the policy when the file is rewritten
+	// anyway. Otherwise, a rewritten tag keeps at least its existing
+	// padding, and Max only limits padding that gets added. Saving
+	// in place always keeps the existing padding, so files are never
+	// rewritten just to shrink it.
+	Shrink bool
+}
+
+// padding returns the amount of padding for frames of the given
+// size, in a tag that currently has existing bytes of padding.
+func (p PaddingPolicy) padding(framesSize, existing int) int {
+	padding := p.Fixed + framesSize*p.Percent/100
+	if p.Max > 0 && padding > p.Max {
+		padding = p.Max
+	}
+	if padding < p.Min {
+		padding = p.Min
+	}
+	if !p.Shrink && padding < existing {
+		padding = existing
+	}
+
+	if p.Block > 0 {
+		size := tagHeaderSize + framesSize + padding
+		if rest := size % p.Block; rest != 0 {
+			padding += p.Block - rest
+		}
+	}
+
+	return padding
+}
+
+// paddingPolicy returns the tag's padding policy, which defaults to
+// that of its options.
+func (t *Tag) paddingPolicy() PaddingPolicy {
+	if t.Padding != nil {
+		return *t.Padding
+	}
+
+	return t.options().Padding
+}
+
+// saveOptions returns the options for writing the tag: its options,
+// with its padding policy.
+func (t *Tag) saveOptions() Options {
+	opts := t.options()
+	opts.Padding = t.paddingPolicy()
+	return opts
+}
+
+// SaveWithPadding saves the file like Save, using the given padding
+// policy instead of the tag's.
+func (f *File) SaveWithPadding(policy PaddingPolicy) error {
+	opts := f.saveOptions()
+	opts.Padding = policy
+	return f.save(opts)
+}
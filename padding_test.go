@@ -0,0 +1,95 @@
+package id3
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPaddingPolicy(t *testing.T) {
+	tests := []struct {
+		policy     PaddingPolicy
+		framesSize int
+		existing   int
+		padding    int
+	}{
+		{PaddingPolicy{}, 100, 0, 0},
+		{PaddingPolicy{Fixed: 1024}, 100, 0, 1024},
+		{PaddingPolicy{Percent: 50}, 1000, 0, 500},
+		{PaddingPolicy{Percent: 50, Max: 200}, 1000, 0, 200},
+		{PaddingPolicy{Percent: 1, Min: 64}, 1000, 0, 64},
+		{PaddingPolicy{Block: 512}, 100, 0, 512 - 110},
+		{PaddingPolicy{Fixed: 10, Block: 512}, 500, 0, 10 + 1024 - 520},
+		{PaddingPolicy{Fixed: 500, Max: 200}, 100, 1000, 1000},
+		{PaddingPolicy{Fixed: 500, Max: 200, Shrink: true}, 100, 1000, 200},
+		{PaddingPolicy{Fixed: 500}, 100, 300, 500},
+	}
+
+	for _, test := range tests {
+		if res := test.policy.padding(test.framesSize, test.existing); res != test.padding {
+			t.Errorf("%+v: padding for %d bytes and %d existing is %d, expected %d",
+				test.policy, test.framesSize, test.existing, res, test.padding)
+		}
+	}
+}
+
+func TestPaddingMax(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xAA}, 1000)
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.Padding = &PaddingPolicy{Fixed: 10000}
+	buf := new(bytes.Buffer)
+	if err := tag.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(audio)
+	name := writeTestFile(t, buf.Bytes())
+
+	size := func() int64 {
+		stat, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stat.Size()
+	}
+	before := size()
+
+	f, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Excessive padding doesn't cause the file to be rewritten.
+	policy := PaddingPolicy{Fixed: 2000, Max: 1000}
+	if err := f.SaveWithPadding(policy); err != nil {
+		t.Fatal(err)
+	}
+	if size() != before {
+		t.Fatal("File was rewritten")
+	}
+
+	// When the file has to be rewritten, the existing padding is
+	// kept.
+	existing := int(f.Header.Size) - f.framesSize()
+	f.Frames["PRIV"] = []Frame{PrivateFrame{FrameHeader: FrameHeader{id: "PRIV"}, Owner: []byte("owner"), Data: make([]byte, 20000)}}
+	if err := f.SaveWithPadding(policy); err != nil {
+		t.Fatal(err)
+	}
+	if padding := int(f.Header.Size) - f.framesSize(); padding != existing {
+		t.Fatalf("Padding is %d bytes, expected %d", padding, existing)
+	}
+
+	// With Shrink, the padding shrinks.
+	f.Frames["PRIV"] = append(f.Frames["PRIV"], PrivateFrame{FrameHeader: FrameHeader{id: "PRIV"}, Owner: []byte("other"), Data: make([]byte, 20000)})
+	policy.Shrink = true
+	if err := f.SaveWithPadding(policy); err != nil {
+		t.Fatal(err)
+	}
+	if padding := int(f.Header.Size) - f.framesSize(); padding != 1000 {
+		t.Fatalf("Padding is %d bytes, expected 1000", padding)
+	}
+	if size() != int64(tagHeaderSize+f.Header.Size+len(audio)) {
+		t.Fatal("Padding wasn't shrunk")
+	}
+}
@@ -0,0 +1,128 @@
+package id3
+
+import (
+	"errors"
+	"io"
+)
+
+// A Reader reads the frames of a tag one at a time, without building
+// a Tag. Frame bodies are only read when requested, so that tags of
+// any size can be processed with bounded memory, and corrupt tags can
+// be inspected up to the first invalid frame.
+//
+// Unlike Parse, a Reader doesn't upgrade frames of older versions,
+// apart from their flags. The bodies of compressed, encrypted or
+// grouped frames keep the layout of their version until decoded.
+type Reader struct {
+	// Limits restrict the frames the Reader accepts. NewReader
+	// initializes them to the limits of DefaultOptions.
+	Limits Limits
+
+	header TagHeader
+	r      *io.LimitedReader // The remainder of the tag
+	size   int64             // The size of the tag, as far as it can be read
+	frame  *RawFrame         // The current frame
+	n      int               // Number of frames read
+	err    error
+}
+
+// A RawFrame is a frame as returned by Reader.Next.
+type RawFrame struct {
+	Header FrameHeader
+	// Offset is the offset of the frame's header from the beginning
+	// of the tag.
+	Offset int64
+	// Size is the size of the frame's body.
+	Size int
+
+	body *io.LimitedReader
+}
+
+// NewReader reads the header of the tag at the beginning of r and
+// returns a Reader for its frames.
+func NewReader(r io.Reader) (*Reader, error) {
+	avail := available(r)
+	header, err := ParseHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Flags.ExtendedHeader() {
+		return nil, ErrNoExtendedHeader
+	}
+	if header.Flags.Unsynchronisation() {
+		return nil, ErrNoUnsynchronizedTag
+	}
+
+	tr := newTagReader(r, header.Size, avail)
+	return &Reader{
+		Limits: defaultLimits,
+		header: header,
+		r:      tr,
+		size:   tr.N,
+	}, nil
+}
+
+// Header returns the header of the tag.
+func (r *Reader) Header() TagHeader {
+	return r.header
+}
+
+// Next advances to the next frame, skipping what remains of the
+// previous frame's body. It returns io.EOF at the end of the tag. An
+// invalid frame header yields a NotAFrameHeader error; the Reader
+// cannot continue after errors.
+func (r *Reader) Next() (*RawFrame, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if r.frame != nil {
+		r.err = skip(r.r, r.frame.body.N)
+		r.frame = nil
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	offset := tagHeaderSize + r.size - r.r.N
+	header, size, err := readFrameHeader(r.r, r.header.Version)
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	r.n++
+	if err := r.Limits.checkFrame(header, size, r.n, r.r.N); err != nil {
+		r.err = err
+		return nil, err
+	}
+
+	r.frame = &RawFrame{
+		Header: header,
+		Offset: offset,
+		Size:   size,
+		body:   &io.LimitedReader{R: r.r, N: int64(size)},
+	}
+	return r.frame, nil
+}
+
+// ID returns the ID of the frame.
+func (f *RawFrame) ID() FrameType {
+	return f.Header.ID()
+}
+
+// Body returns a reader for the frame's body. It is only valid until
+// the next call to Reader.Next.
+func (f *RawFrame) Body() io.Reader {
+	return f.body
+}
+
+// Decode reads and decodes the frame's body, like Parse would. It
+// fails if the body has already been read from.
+func (f *RawFrame) Decode() (Frame, error) {
+	if f.body.N != int64(f.Size) {
+		return nil, errors.New("id3: frame body has already been read")
+	}
+
+	return readFrameBody(f.body, f.Header, f.Size)
+}
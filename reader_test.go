@@ -0,0 +1,64 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	frames := [][]byte{
+		rawFrame("TIT2", 0, []byte("\x00Title")),
+		rawFrame("PRIV", 0, append([]byte("owner\x00"), make([]byte, 100)...)),
+		rawFrame("TALB", 0, []byte("\x00Album")),
+	}
+	body := bytes.Join(frames, nil)
+	// A corrupt frame follows the valid ones.
+	body = append(body, "ab!!\x00\x00\x00\x01\x00\x00x"...)
+	data := append(generateHeader(len(body), 0), body...)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset := int64(tagHeaderSize)
+	for i, id := range []FrameType{"TIT2", "PRIV", "TALB"} {
+		frame, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.ID() != id || frame.Offset != offset || frame.Size != len(frames[i])-frameLength {
+			t.Fatalf("Unexpected frame %s at %d with size %d", frame.ID(), frame.Offset, frame.Size)
+		}
+		offset += int64(len(frames[i]))
+
+		switch id {
+		case "TIT2":
+			// Leave the body unread.
+		case "PRIV":
+			b, err := ioutil.ReadAll(frame.Body())
+			if err != nil || !bytes.Equal(b, frames[i][frameLength:]) {
+				t.Fatalf("Unexpected body %q: %v", b, err)
+			}
+			if _, err := frame.Decode(); err == nil {
+				t.Fatal("Decoding a read body succeeded")
+			}
+		case "TALB":
+			decoded, err := frame.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Value() != "Album" {
+				t.Fatalf("Value is %q", decoded.Value())
+			}
+		}
+	}
+
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Fatalf("Expected error for corrupt frame, got %v", err)
+	} else if _, ok := err.(NotAFrameHeader); !ok {
+		t.Fatalf("Expected NotAFrameHeader, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package id3
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	utf8pkg "unicode/utf8"
+)
+
+// Restrictions are the tag restrictions of ID3v2.4, which limit the
+// size and content of tags meant for devices with few resources. They
+// use the layout of the restrictions byte of the extended header,
+// %ppqrrstt. Tag.Validate checks tags against the restrictions in
+// Tag.Restrictions. The extended header that announces them is
+// neither read nor written, so the restrictions of a file have to be
+// set by the caller.
+type Restrictions byte
+
+// MaxFrames returns the maximum number of frames.
+func (r Restrictions) MaxFrames() int {
+	return [4]int{128, 64, 32, 32}[r>>6]
+}
+
+// MaxTagSize returns the maximum total size of the tag in bytes,
+// including its header and padding.
+func (r Restrictions) MaxTagSize() int {
+	return [4]int{1 << 20, 128 << 10, 40 << 10, 4 << 10}[r>>6]
+}
+
+// RestrictTextEncoding reports whether text may only be encoded as
+// ISO-8859-1 or UTF-8.
+func (r Restrictions) RestrictTextEncoding() bool {
+	return r&0x20 != 0
+}
+
+// MaxTextLength returns the maximum number of characters of a text
+// frame, or 0 if there is no limit. The characters of all values of a
+// frame count towards the limit.
+func (r Restrictions) MaxTextLength() int {
+	return [4]int{0, 1024, 128, 30}[r>>3&3]
+}
+
+// RestrictImageEncoding reports whether pictures may only be PNG or
+// JPEG images.
+func (r Restrictions) RestrictImageEncoding() bool {
+	return r&0x04 != 0
+}
+
+// MaxImageSize returns the maximum width and height of pictures in
+// pixels, or 0 if there is no limit, and whether pictures have to be
+// exactly that large.
+func (r Restrictions) MaxImageSize() (size int, exact bool) {
+	return [4]int{0, 256, 64, 64}[r&3], r&3 == 3
+}
+
+// checkRestrictions checks a frame against the restrictions. It
+// returns the frame, fixed if fixing is enabled, and whether to keep
+// it.
+func (v *validator) checkRestrictions(frame Frame) (Frame, bool) {
+	r := *v.restrictions
+	id := frame.ID()
+
+	switch f := frame.(type) {
+	case TextInformationFrame:
+		if text, ok := v.checkTextLength(id, f.Text, r.MaxTextLength()); !ok {
+			f.Text = text
+			return f, true
+		}
+	case UserTextInformationFrame:
+		if text, ok := v.checkTextLength(id, f.Text, r.MaxTextLength()); !ok {
+			f.Text = text
+			return f, true
+		}
+	case PictureFrame:
+		mime := strings.ToLower(f.MIMEType)
+		if r.RestrictImageEncoding() && mime != "image/png" && mime != "image/jpeg" {
+			v.report(id, SeverityError, "removed frame", "picture of type %q isn't PNG or JPEG", f.MIMEType)
+			return f, false
+		}
+		if size, exact := r.MaxImageSize(); size > 0 {
+			config, err := decodeImageConfig(mime, f.Data)
+			switch {
+			case err != nil:
+				v.report(id, SeverityError, "removed frame", "cannot determine size of picture: %v", err)
+				return f, false
+			case exact && (config.Width != size || config.Height != size):
+				v.report(id, SeverityError, "removed frame", "picture is %dx%d pixels instead of %dx%d", config.Width, config.Height, size, size)
+				return f, false
+			case config.Width > size || config.Height > size:
+				v.report(id, SeverityError, "removed frame", "picture is %dx%d pixels, larger than %dx%d", config.Width, config.Height, size, size)
+				return f, false
+			}
+		}
+	}
+
+	return frame, true
+}
+
+// checkTextLength checks that text has at most limit characters, not
+// counting separators between values. It returns the truncated text
+// and whether the text was within the limit.
+func (v *validator) checkTextLength(id FrameType, text string, limit int) (string, bool) {
+	if limit == 0 {
+		return text, true
+	}
+
+	n := 0
+	for i, c := range text {
+		if c == 0 {
+			continue
+		}
+		if n == limit {
+			length := n + utf8pkg.RuneCountInString(strings.Replace(text[i:], "\x00", "", -1))
+			v.report(id, SeverityError, "truncated text", "text is %d characters long, exceeding the limit of %d", length, limit)
+			return strings.TrimRight(text[:i], "\x00"), false
+		}
+		n++
+	}
+
+	return text, true
+}
+
+// decodeImageConfig returns the dimensions of a PNG or JPEG image.
+func decodeImageConfig(mime string, data []byte) (image.Config, error) {
+	if mime == "image/png" {
+		return png.DecodeConfig(bytes.NewReader(data))
+	}
+
+	return jpeg.DecodeConfig(bytes.NewReader(data))
+}
+
+// checkTagRestrictions checks the restrictions that apply to the tag
+// as a whole, after its frames have been checked.
+func (t *Tag) checkTagRestrictions(v *validator) {
+	r := *v.restrictions
+
+	if r.RestrictTextEncoding() {
+		for _, frames := range t.Frames {
+			if writesUTF16(frames, t.Encoding) {
+				v.report("", SeverityError, "set encoding policy to UTF-8", "encoding policy writes UTF-16 text")
+				if v.fix {
+					t.Encoding = AlwaysUTF8
+				}
+				break
+			}
+		}
+	}
+
+	n := 0
+	for _, frames := range t.Frames {
+		n += len(frames)
+	}
+	if n > r.MaxFrames() {
+		v.report("", SeverityError, "", "tag has %d frames, exceeding the limit of %d", n, r.MaxFrames())
+	}
+	framesSize := t.framesSize()
+	padding := t.paddingPolicy().padding(framesSize, t.padding)
+	if size := tagHeaderSize + framesSize + padding; size > r.MaxTagSize() {
+		v.report("", SeverityError, "", "tag is %d bytes large, exceeding the limit of %d", size, r.MaxTagSize())
+	}
+}
+
+// writesUTF16 reports whether the encoding policy writes any of the
+// frames as UTF-16.
+func writesUTF16(frames []Frame, policy EncodingPolicy) bool {
+	for _, frame := range frames {
+		var texts []string
+		switch f := frame.(type) {
+		case TextInformationFrame:
+			texts = []string{f.Text}
+		case UserTextInformationFrame:
+			texts = []string{f.Description, f.Text}
+		case UserDefinedURLLinkFrame:
+			texts = []string{f.Description}
+		case CommentFrame:
+			texts = []string{f.Description, f.Text}
+		case PictureFrame:
+			texts = []string{f.Description}
+		case UnsynchronisedLyricsFrame:
+			texts = []string{f.Description, f.Lyrics}
+		default:
+			continue
+		}
+
+		if policy.encoding(texts...) == utf16bom {
+			return true
+		}
+	}
+
+	return false
+}
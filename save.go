@@ -0,0 +1,235 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+)
+
+// PreserveFlags select attributes of a file that Save keeps when it
+// has to replace the file.
+type PreserveFlags int
+
+const (
+	// PreserveMode keeps the permission bits.
+	PreserveMode PreserveFlags = 1 << iota
+	// PreserveOwner keeps the owner and group, if the process is
+	// allowed to set them.
+	PreserveOwner
+	// PreserveModTime keeps the modification time.
+	PreserveModTime
+)
+
+// saveNew writes the file, with the tag at its beginning or end, to a
+// temporary file next to it, which then replaces the original. Should
+// saving fail or the system crash, the original stays intact. If no
+// temporary file can be created in the file's directory, saving fails,
+// unless Options.RewriteInPlace allows rewriting the file in place.
+func (f *File) saveNew(framesSize int, opts Options) error {
+	fsys, target, err := f.target()
+	if err != nil {
+		return err
+	}
+	stat, err := f.f.Stat()
+	if err != nil {
+		return err
+	}
+	perm := fs.FileMode(0666)
+	if opts.Preserve&PreserveMode != 0 {
+		perm = stat.Mode().Perm()
+	}
+
+	// The audio data will move, so ASPI frames have to point to its
+	// new location.
+	var audioStart int64
+	if !f.Append {
+		audioStart = int64(tagHeaderSize + framesSize + opts.Padding.padding(framesSize, f.padding))
+	}
+	f.relocateSeekIndex(audioStart - f.audioStart)
+
+	log := f.loggerFor(&opts)
+	name, tmp, err := createTemp(fsys, target, perm)
+	switch {
+	case err == nil:
+		log.Debug("writing to temporary file", "temp", name, "action", "replace file")
+		err = f.replace(fsys, target, name, tmp, stat, opts)
+	case opts.RewriteInPlace:
+		log.Warn("cannot create temporary file", "action", "rewrite in place", "error", err)
+		err = f.rewrite(opts)
+	}
+	if err != nil {
+		f.relocateSeekIndex(f.audioStart - audioStart)
+		return err
+	}
+
+	stat, err = f.f.Stat()
+	if err != nil {
+		return err
+	}
+	f.fileSize = stat.Size()
+	f.setSavedHeader(framesSize, opts)
+	return f.layout(!f.Append)
+}
+
+// replace writes the file according to opts to the temporary file
+// tmp, named name, and renames it over target, the original in fsys,
+// whose attributes are described by stat.
+func (f *File) replace(fsys WriteFS, target, name string, tmp WritableFile, stat fs.FileInfo, opts Options) (err error) {
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			fsys.Remove(name)
+		}
+	}()
+
+	preserve := opts.Preserve
+
+	err = f.saveTo(tmp, opts)
+	if err != nil {
+		return err
+	}
+
+	// Files of the operating system are subject to the umask, and
+	// have an owner.
+	if osFile, ok := tmp.(*os.File); ok {
+		if preserve&PreserveMode != 0 {
+			err = osFile.Chmod(stat.Mode().Perm())
+			if err != nil {
+				return err
+			}
+		}
+		if preserve&PreserveOwner != 0 {
+			if err := chownLike(osFile, stat); err != nil {
+				f.loggerFor(&opts).Warn("cannot preserve owner", "action", "kept new owner", "error", err)
+			}
+		}
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if dir, ok := fsys.(dirFS); ok && preserve&PreserveModTime != 0 {
+		err = dir.chtimes(name, stat.ModTime())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fsys.Rename(name, target)
+	if err != nil {
+		return err
+	}
+	renamed = true
+
+	// The old handle refers to the replaced file.
+	file, err := fsys.OpenFile(target, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	f.f.Close()
+	f.f = file
+	f.src = file
+
+	return nil
+}
+
+// syncDir flushes a directory, making a rename in it durable. Not all
+// systems support it, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// rewrite generates the file according to opts in a buffer and
+// overwrites the original with it. Unlike replace, it isn't safe
+// against crashes, which is why it requires Options.RewriteInPlace.
+func (f *File) rewrite(opts Options) error {
+	var buf io.ReadWriter
+	log := f.loggerFor(&opts)
+
+	// Work in memory if the old file is smaller than the threshold,
+	// use a temporary file otherwise.
+	if f.fileSize < opts.InMemoryThreshold {
+		log.Debug("rewriting file", "action", "buffer in memory")
+		buf = new(bytes.Buffer)
+	} else {
+		log.Debug("rewriting file", "action", "buffer in temporary file")
+		newFile, err := ioutil.TempFile(opts.TempDir, "id3")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(newFile.Name())
+		defer newFile.Close()
+		buf = newFile
+	}
+
+	err := f.saveTo(buf, opts)
+	if err != nil {
+		return err
+	}
+
+	// We successfully generated a new file, so replace the old
+	// one with it.
+	err = truncate(f.f)
+	if err != nil {
+		return err
+	}
+
+	if newFile, ok := buf.(*os.File); ok {
+		_, err = newFile.Seek(0, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(f.f, buf)
+	if err != nil {
+		return err
+	}
+
+	return f.f.Sync()
+}
+
+// saveInplace overwrites the existing tag, which has enough room for
+// the frames. The whole tag is encoded before the file is modified.
+func (f *File) saveInplace(framesSize int, opts Options) error {
+	// A footer, if there was one, becomes part of the padding.
+	size := int(f.audioStart) - tagHeaderSize
+
+	buf := bytes.NewBuffer(make([]byte, 0, f.audioStart))
+	buf.Write(generateHeader(size, 0))
+	err := f.encodeFrames(buf, f.loggerFor(&opts))
+	if err != nil {
+		return err
+	}
+	// Blank out remainder of previous tags
+	buf.Write(make([]byte, size-framesSize))
+
+	_, err = f.f.WriteAt(buf.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+	err = f.f.Sync()
+	if err != nil {
+		return err
+	}
+
+	f.Header.Version = 0x0400
+	f.Header.Size = size
+	f.Header.Flags = 0
+	f.padding = size - framesSize
+	return nil
+}
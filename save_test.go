@@ -0,0 +1,128 @@
+package id3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveReplacesFile(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xAA}, 1000)
+	name := writeTestFile(t, audio)
+	if err := os.Chmod(name, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Preserve = PreserveMode | PreserveModTime
+	f, err := OpenWithOptions(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Unrepresentable text makes saving fail before the file is
+	// replaced.
+	f.Frames["WOAR"] = []Frame{URLLinkFrame{FrameHeader: FrameHeader{id: "WOAR"}, URL: "€"}}
+	if err := f.Save(); err == nil {
+		t.Fatal("Saving unrepresentable URL succeeded")
+	}
+	if data, _ := os.ReadFile(name); !bytes.Equal(data, audio) {
+		t.Fatal("Failed save modified the file")
+	}
+
+	f.RemoveFrames("WOAR")
+	f.SetTitle("Title")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Temporary files were left behind: %v", entries)
+	}
+
+	stat, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0640 {
+		t.Errorf("Mode is %v, expected %v", stat.Mode().Perm(), os.FileMode(0640))
+	}
+	if !stat.ModTime().Equal(mtime) {
+		t.Errorf("Modification time is %v, expected %v", stat.ModTime(), mtime)
+	}
+
+	// The file can be saved again, in place this time.
+	f.SetAlbum("Album")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, audio) || int64(len(data)) != stat.Size() {
+		t.Fatal("Audio data was modified")
+	}
+	f, err = Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Title() != "Title" || f.Album() != "Album" {
+		t.Fatalf("Unexpected tag %q, %q", f.Title(), f.Album())
+	}
+}
+
+func TestSaveThroughSymlink(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xAA}, 100)
+	target := writeTestFile(t, audio)
+	link := filepath.Join(t.TempDir(), "link.mp3")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip(err)
+	}
+
+	f, err := Open(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.SetTitle("Title")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("Saving replaced the symbolic link")
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == len(audio) || !bytes.HasSuffix(data, audio) {
+		t.Fatal("Saving didn't update the link's target")
+	}
+	entries, err := os.ReadDir(filepath.Dir(link))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Temporary files were left next to the link: %v", entries)
+	}
+}
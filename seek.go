@@ -0,0 +1,300 @@
+package id3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNoSeekIndex   = errors.New("id3: tag has neither an MLLT nor an ASPI frame")
+	ErrNoAudioFrames = errors.New("id3: no MPEG audio frames found")
+)
+
+// Offset returns the offset in bytes, relative to the first MPEG
+// frame, of the last reference point at or before d.
+func (f MPEGLocationLookupTableFrame) Offset(d time.Duration) int64 {
+	var (
+		offset int64
+		millis int64
+	)
+
+	target := int64(d / time.Millisecond)
+	for _, ref := range f.References {
+		nextMillis := millis + int64(f.MillisecondsBetweenReference) + int64(ref.MillisecondsDeviation)
+		if nextMillis > target {
+			break
+		}
+
+		offset += int64(f.BytesBetweenReference) + int64(ref.BytesDeviation)
+		millis = nextMillis
+	}
+
+	return offset
+}
+
+// Offset returns the offset in bytes, relative to the beginning of
+// the file, of the index point closest to but not after d. length is
+// the total playing time of the indexed audio.
+func (f AudioSeekPointIndexFrame) Offset(d, length time.Duration) int64 {
+	if len(f.Points) == 0 || length <= 0 || d <= 0 {
+		return f.DataStart
+	}
+
+	i := int(int64(d) * int64(len(f.Points)) / int64(length))
+	if i >= len(f.Points) {
+		i = len(f.Points) - 1
+	}
+
+	return f.DataStart + int64(f.Points[i])*f.DataLength>>f.BitsPerPoint
+}
+
+// AudioOffset maps a playback position to an offset in bytes
+// relative to the beginning of the audio data, using the tag's MLLT
+// frame or, if there is none, its ASPI frame. The offsets of the
+// former count from the first MPEG frame, which will be searched for
+// in the audio. The latter requires knowing the length of the audio,
+// which will be taken from the TLEN frame or determined by scanning
+// the audio.
+func (f *File) AudioOffset(d time.Duration) (int64, error) {
+	if frames := f.Frames["MLLT"]; len(frames) > 0 {
+		if mllt, ok := frames[0].(MPEGLocationLookupTableFrame); ok {
+			_, err := f.audioReader.Seek(0, io.SeekStart)
+			if err != nil {
+				return 0, err
+			}
+			first, err := findMPEGFrame(f.audioReader)
+			if err != nil {
+				return 0, err
+			}
+			if first < 0 {
+				return 0, ErrNoAudioFrames
+			}
+			return first + mllt.Offset(d), nil
+		}
+	}
+
+	frames := f.Frames["ASPI"]
+	if len(frames) == 0 {
+		return 0, ErrNoSeekIndex
+	}
+	aspi, ok := frames[0].(AudioSeekPointIndexFrame)
+	if !ok {
+		return 0, ErrNoSeekIndex
+	}
+
+	length := f.Length()
+	if length == 0 {
+		_, err := f.audioReader.Seek(0, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		_, length, err = scanMPEGFrames(f.audioReader)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	offset := aspi.Offset(d, length) - f.audioStart
+	if offset < 0 {
+		offset = 0
+	}
+
+	return offset, nil
+}
+
+// GenerateSeekPointIndex scans the file's audio data and builds an
+// ASPI frame with the given number of index points. The frame is not
+// added to the tag.
+func (f *File) GenerateSeekPointIndex(points int) (AudioSeekPointIndexFrame, error) {
+	frame := AudioSeekPointIndexFrame{
+		FrameHeader:  FrameHeader{id: "ASPI"},
+		BitsPerPoint: 16,
+	}
+
+	if points < 1 {
+		points = 1
+	}
+	if points > 0xFFFF {
+		points = 0xFFFF
+	}
+
+	_, err := f.audioReader.Seek(0, io.SeekStart)
+	if err != nil {
+		return frame, err
+	}
+
+	mpegFrames, length, err := scanMPEGFrames(f.audioReader)
+	if err != nil {
+		return frame, err
+	}
+	if len(mpegFrames) == 0 {
+		return frame, ErrNoAudioFrames
+	}
+
+	last := mpegFrames[len(mpegFrames)-1]
+	frame.DataStart = f.audioStart
+	frame.DataLength = last.offset + int64(last.size)
+	frame.Points = make([]uint16, points)
+
+	for i := range frame.Points {
+		t := time.Duration(int64(length) * int64(i) / int64(points))
+		j := sort.Search(len(mpegFrames), func(j int) bool {
+			return mpegFrames[j].start > t
+		}) - 1
+		if j < 0 {
+			j = 0
+		}
+
+		fraction := (mpegFrames[j].offset << 16) / frame.DataLength
+		if fraction > 0xFFFF {
+			fraction = 0xFFFF
+		}
+		frame.Points[i] = uint16(fraction)
+	}
+
+	return frame, nil
+}
+
+// relocateSeekIndex adjusts the data start of all ASPI frames after
+// the audio data moved by delta bytes.
+func (t *Tag) relocateSeekIndex(delta int64) {
+	for i, frame := range t.Frames["ASPI"] {
+		if aspi, ok := frame.(AudioSeekPointIndexFrame); ok {
+			aspi.DataStart += delta
+			t.Frames["ASPI"][i] = aspi
+		}
+	}
+}
+
+// mergeSeekTags follows the tag's SEEK frame to the next tag in the
+// file and merges its frames into the tag, repeating the process for
+// SEEK frames in the merged tags. Frames that are already present take
+// precedence over frames with the same ID in later tags. Data that
+// looks like a tag header but doesn't start a valid tag is skipped.
+func (f *File) mergeSeekTags() error {
+	if f.audioStart == 0 {
+		// SEEK frames are only followed from a tag at the beginning
+		// of the file.
+		return nil
+	}
+
+	tag := f.Tag
+	end := f.audioStart
+	for {
+		frames := tag.Frames["SEEK"]
+		if len(frames) == 0 {
+			return nil
+		}
+		seek, ok := frames[0].(SeekFrame)
+		if !ok {
+			return nil
+		}
+
+		next, pos, err := f.nextSeekTag(end + seek.MinimumOffset)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			f.logger().Warn("no tag found after SEEK frame", "frame", "SEEK", "action", "ignored")
+			return nil
+		}
+
+		f.logger().Debug("merging tag", "frame", "SEEK", "offset", pos, "action", "merged")
+		f.mergeFrames(next)
+
+		tag = next
+		end = pos + tagHeaderSize + int64(next.Header.Size)
+	}
+}
+
+// nextSeekTag parses the first tag at or after offset, skipping
+// matches of the tag identifier that aren't valid tags, and returns
+// the tag and its offset. It returns a nil tag if there is none.
+func (f *File) nextSeekTag(offset int64) (*Tag, int64, error) {
+	for {
+		pos, err := f.findTag(offset)
+		if err != nil || pos < 0 {
+			return nil, pos, err
+		}
+
+		tag, err := parse(namedReader{io.NewSectionReader(f.src, pos, f.fileSize-pos), f.name}, f.opts)
+		if err == nil {
+			return tag, pos, nil
+		}
+		f.logger().Warn("cannot parse tag after SEEK frame", "frame", "SEEK", "offset", pos, "error", err, "action", "skipped")
+		offset = pos + 1
+	}
+}
+
+// findTag returns the offset of the first ID3v2 header at or after
+// offset, or -1 if there is none.
+func (f *File) findTag(offset int64) (int64, error) {
+	if offset >= f.fileSize {
+		return -1, nil
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(f.src, offset, f.fileSize-offset))
+	for pos := offset; ; pos++ {
+		b, err := br.Peek(tagHeaderSize)
+		if err != nil {
+			if err == io.EOF {
+				return -1, nil
+			}
+			return 0, err
+		}
+
+		if bytes.HasPrefix(b, id3byte) {
+			_, err := readHeader(bytes.NewReader(b))
+			if err == nil {
+				return pos, nil
+			}
+		}
+
+		br.Discard(1)
+	}
+}
+
+// bitReader reads big endian, bit packed values.
+type bitReader struct {
+	data []byte
+	pos  int // position in bits
+}
+
+func (br *bitReader) read(bits byte) uint32 {
+	var v uint32
+	for i := byte(0); i < bits; i++ {
+		v <<= 1
+		if br.pos/8 < len(br.data) && br.data[br.pos/8]&(0x80>>uint(br.pos%8)) != 0 {
+			v |= 1
+		}
+		br.pos++
+	}
+
+	return v
+}
+
+// bitWriter writes big endian, bit packed values.
+type bitWriter struct {
+	data []byte
+	pos  int // position in bits
+}
+
+func (bw *bitWriter) write(v uint32, bits byte) {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if bw.pos%8 == 0 {
+			bw.data = append(bw.data, 0)
+		}
+		if v&(1<<uint(i)) != 0 {
+			bw.data[bw.pos/8] |= 0x80 >> uint(bw.pos%8)
+		}
+		bw.pos++
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	return bw.data
+}
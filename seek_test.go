@@ -0,0 +1,313 @@
+package id3
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mpegTestFrame is an MPEG-1 layer III frame at 128 kbit/s and 44.1
+// kHz, which is 417 bytes long and lasts 1152 samples.
+func mpegTestFrame() []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return frame
+}
+
+func TestMLLTRoundTrip(t *testing.T) {
+	in := MPEGLocationLookupTableFrame{
+		FrameHeader:                  FrameHeader{id: "MLLT"},
+		FramesBetweenReference:       10,
+		BytesBetweenReference:        4170,
+		MillisecondsBetweenReference: 261,
+		BitsForBytesDeviation:        12,
+		BitsForMillisecondsDeviation: 4,
+		References: []MPEGLocationReference{
+			{BytesDeviation: 1, MillisecondsDeviation: 0},
+			{BytesDeviation: 4095, MillisecondsDeviation: 15},
+			{BytesDeviation: 7, MillisecondsDeviation: 1},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := in.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != in.Size() {
+		t.Fatalf("Encoded %d bytes, Size() reported %d", buf.Len(), in.Size())
+	}
+
+	in.raw = append([]byte(nil), buf.Bytes()...)
+	out, err := readFrame(buf, 0x0400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := out.(MPEGLocationLookupTableFrame); ok {
+		in.orig = f.orig
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Expected %#v, got %#v", in, out)
+	}
+
+	tests := []struct {
+		in  time.Duration
+		out int64
+	}{
+		{0, 0},
+		{260 * time.Millisecond, 0},
+		{261 * time.Millisecond, 4171},
+		{600 * time.Millisecond, 4171 + 8265},
+		{time.Hour, 4171 + 8265 + 4177},
+	}
+	for _, test := range tests {
+		if res := in.Offset(test.in); res != test.out {
+			t.Errorf("Offset(%s) = %d, expected %d", test.in, res, test.out)
+		}
+	}
+}
+
+func TestMLLTOddReferences(t *testing.T) {
+	// Three references of 2+2 bits take 12 bits, padded to 16.
+	in := MPEGLocationLookupTableFrame{
+		FrameHeader:                  FrameHeader{id: "MLLT"},
+		FramesBetweenReference:       1,
+		BytesBetweenReference:        417,
+		MillisecondsBetweenReference: 26,
+		BitsForBytesDeviation:        2,
+		BitsForMillisecondsDeviation: 2,
+		References: []MPEGLocationReference{
+			{BytesDeviation: 1, MillisecondsDeviation: 2},
+			{BytesDeviation: 3, MillisecondsDeviation: 0},
+			{BytesDeviation: 2, MillisecondsDeviation: 1},
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := in.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := readFrame(buf, 0x0400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := out.(MPEGLocationLookupTableFrame).References; !reflect.DeepEqual(refs, in.References) {
+		t.Fatalf("Expected %v, got %v", in.References, refs)
+	}
+}
+
+func TestMLLTPadding(t *testing.T) {
+	// Two references of 12 bits, padded to 3 bytes, and a trailing
+	// byte that doesn't make up a whole reference.
+	body := []byte{0, 1, 0, 0, 100, 0, 0, 26, 8, 4, 0x12, 0x34, 0x56, 0xFF}
+	buf := bytes.NewBuffer(rawFrame("MLLT", 0, body))
+	frame, err := readFrame(buf, 0x0400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MPEGLocationReference{
+		{BytesDeviation: 0x12, MillisecondsDeviation: 0x3},
+		{BytesDeviation: 0x45, MillisecondsDeviation: 0x6},
+	}
+	if refs := frame.(MPEGLocationLookupTableFrame).References; !reflect.DeepEqual(refs, want) {
+		t.Fatalf("Expected %v, got %v", want, refs)
+	}
+}
+
+func TestMLLTAudioOffset(t *testing.T) {
+	// Junk before the first MPEG frame, like a VBR header in a
+	// broken frame.
+	audio := make([]byte, 10)
+	for i := 0; i < 10; i++ {
+		audio = append(audio, mpegTestFrame()...)
+	}
+
+	f := &File{
+		Tag:         NewTag(),
+		audioReader: bytes.NewReader(audio),
+	}
+	f.Frames["MLLT"] = []Frame{MPEGLocationLookupTableFrame{
+		FrameHeader:                  FrameHeader{id: "MLLT"},
+		FramesBetweenReference:       1,
+		BytesBetweenReference:        417,
+		MillisecondsBetweenReference: 26,
+		References:                   make([]MPEGLocationReference, 9),
+	}}
+	offset, err := f.AudioOffset(52 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 10+2*417 {
+		t.Fatalf("AudioOffset = %d, expected %d", offset, 10+2*417)
+	}
+}
+
+func TestASPIRoundTrip(t *testing.T) {
+	for _, bits := range []byte{8, 16} {
+		in := AudioSeekPointIndexFrame{
+			FrameHeader:  FrameHeader{id: "ASPI"},
+			DataStart:    1000,
+			DataLength:   4096,
+			BitsPerPoint: bits,
+			Points:       []uint16{0, 64, 128, 192},
+		}
+
+		buf := new(bytes.Buffer)
+		if err := in.Encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != in.Size() {
+			t.Fatalf("Encoded %d bytes, Size() reported %d", buf.Len(), in.Size())
+		}
+
+		in.raw = append([]byte(nil), buf.Bytes()...)
+		out, err := readFrame(buf, 0x0400)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f, ok := out.(AudioSeekPointIndexFrame); ok {
+			in.orig = f.orig
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("Expected %#v, got %#v", in, out)
+		}
+	}
+
+	aspi := AudioSeekPointIndexFrame{
+		DataStart:    1000,
+		DataLength:   4096,
+		BitsPerPoint: 8,
+		Points:       []uint16{0, 64, 128, 192},
+	}
+	if res := aspi.Offset(5*time.Second, 10*time.Second); res != 1000+2048 {
+		t.Fatalf("Offset = %d, expected %d", res, 1000+2048)
+	}
+}
+
+func TestScanMPEGFrames(t *testing.T) {
+	audio := []byte{0, 1, 2}
+	for i := 0; i < 100; i++ {
+		audio = append(audio, mpegTestFrame()...)
+	}
+	audio = append(audio, []byte("TAG")...)
+
+	frames, length, err := scanMPEGFrames(bytes.NewReader(audio))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 100 {
+		t.Fatalf("Found %d frames, expected 100", len(frames))
+	}
+	if frames[1].offset != 3+417 {
+		t.Fatalf("Second frame at offset %d, expected %d", frames[1].offset, 3+417)
+	}
+	if expected := 100 * (1152 * time.Second / 44100); length != expected {
+		t.Fatalf("Length is %s, expected %s", length, expected)
+	}
+}
+
+func TestGenerateSeekPointIndex(t *testing.T) {
+	var audio []byte
+	for i := 0; i < 100; i++ {
+		audio = append(audio, mpegTestFrame()...)
+	}
+
+	f := &File{
+		Tag:         NewTag(),
+		audioStart:  2048,
+		audioReader: bytes.NewReader(audio),
+	}
+	aspi, err := f.GenerateSeekPointIndex(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aspi.DataStart != 2048 || aspi.DataLength != int64(len(audio)) {
+		t.Fatalf("Wrong indexed range: %d+%d", aspi.DataStart, aspi.DataLength)
+	}
+
+	f.Frames["ASPI"] = []Frame{aspi}
+	f.SetLength(100 * 1152 * time.Second / 44100)
+	offset, err := f.AudioOffset(f.Length() / 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The index point has a precision of 16 bits, so the offset may
+	// be slightly before the actual frame.
+	if offset > 50*417 || offset < 50*417-int64(len(audio))>>16-1 {
+		t.Fatalf("AudioOffset = %d, expected about %d", offset, 50*417)
+	}
+}
+
+func TestPOSSRoundTrip(t *testing.T) {
+	in := PositionSynchronisationFrame{
+		FrameHeader:     FrameHeader{id: "POSS"},
+		TimestampFormat: TimestampMilliseconds,
+		Position:        0x012345,
+	}
+
+	buf := new(bytes.Buffer)
+	if err := in.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != frameLength+4 {
+		t.Fatalf("Encoded %d bytes, expected %d", buf.Len(), frameLength+4)
+	}
+
+	in.raw = append([]byte(nil), buf.Bytes()...)
+	out, err := readFrame(buf, 0x0400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := out.(PositionSynchronisationFrame); ok {
+		in.orig = f.orig
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Expected %#v, got %#v", in, out)
+	}
+}
+
+func TestSeekTagMerging(t *testing.T) {
+	const gap = 100
+
+	second := NewTag()
+	second.SetTitle("Ignored")
+	second.SetAlbum("Album")
+	secondBuf := new(bytes.Buffer)
+	if err := second.Encode(secondBuf); err != nil {
+		t.Fatal(err)
+	}
+
+	first := NewTag()
+	first.SetTitle("Title")
+	first.Frames["SEEK"] = []Frame{SeekFrame{FrameHeader: FrameHeader{id: "SEEK"}, MinimumOffset: gap}}
+	buf := new(bytes.Buffer)
+	if err := first.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(make([]byte, gap+7))
+	// A header that isn't followed by a valid tag.
+	buf.Write(generateHeader(20, 0))
+	buf.Write(rawFrame("TIT2", 0, make([]byte, 1000))[:20])
+	buf.Write(secondBuf.Bytes())
+
+	name := writeTestFile(t, buf.Bytes())
+
+	logger := new(testLogger)
+	opts := DefaultOptions()
+	opts.Logger = logger
+	f, err := OpenWithOptions(name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Title() != "Title" {
+		t.Errorf("Title is %q, expected %q", f.Title(), "Title")
+	}
+	if f.Album() != "Album" {
+		t.Errorf("Album is %q, expected %q", f.Album(), "Album")
+	}
+	if log := strings.Join(logger.lines, ""); !strings.Contains(log, "cannot parse tag after SEEK frame") {
+		t.Errorf("Skipped tag wasn't logged:\n%s", log)
+	}
+}
@@ -0,0 +1,149 @@
+package id3
+
+import (
+	"fmt"
+	"time"
+)
+
+// Precision specifies which components of a Timestamp are present.
+type Precision int
+
+const (
+	PrecisionYear Precision = iota + 1
+	PrecisionMonth
+	PrecisionDay
+	PrecisionHour
+	PrecisionMinute
+	PrecisionSecond
+)
+
+// A Timestamp is a point in time as stored in frames like TDRC. ID3v2.4
+// allows omitting trailing components, so that e.g. "2009" denotes a
+// year, not the first second of it. Precision records which components
+// are present. The zero value denotes a missing timestamp.
+type Timestamp struct {
+	Time      time.Time
+	Precision Precision
+}
+
+// NewTimestamp returns a timestamp with the given precision, dropping
+// the components of t beyond it.
+func NewTimestamp(t time.Time, p Precision) Timestamp {
+	ts := Timestamp{Precision: p}
+	switch p {
+	case PrecisionYear:
+		ts.Time = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case PrecisionMonth:
+		ts.Time = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case PrecisionDay:
+		ts.Time = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case PrecisionHour:
+		ts.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case PrecisionMinute:
+		ts.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	case PrecisionSecond:
+		ts.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	default:
+		return Timestamp{}
+	}
+
+	return ts
+}
+
+// ParseTimestamp parses a timestamp in one of the formats allowed by
+// ID3v2.4, from "yyyy" to "yyyy-MM-ddTHH:mm:ss".
+func ParseTimestamp(s string) (Timestamp, error) {
+	var err error
+	for i, format := range timeFormats {
+		var t time.Time
+		t, err = time.Parse(format, s)
+		if err == nil {
+			return Timestamp{Time: t, Precision: PrecisionSecond - Precision(i)}, nil
+		}
+	}
+
+	return Timestamp{}, fmt.Errorf("id3: malformed timestamp %q: %s", s, err)
+}
+
+// IsZero reports whether the timestamp is missing.
+func (ts Timestamp) IsZero() bool {
+	return ts.Precision == 0
+}
+
+// String formats the timestamp with its precision, or returns an
+// empty string for the zero timestamp.
+func (ts Timestamp) String() string {
+	if ts.Precision < PrecisionYear || ts.Precision > PrecisionSecond {
+		return ""
+	}
+
+	return ts.Time.Format(timeFormats[PrecisionSecond-ts.Precision])
+}
+
+// GetTextFrameTimestamp returns the first timestamp stored in a text
+// frame, or the zero timestamp if the frame is missing or malformed.
+func (t *Tag) GetTextFrameTimestamp(name FrameType) Timestamp {
+	values := t.GetTextFrameSlice(name)
+	if len(values) == 0 {
+		return Timestamp{}
+	}
+
+	ts, err := ParseTimestamp(values[0])
+	if err != nil {
+		t.logger().Warn("malformed timestamp", "frame", name, "action", "ignored", "error", err)
+		return Timestamp{}
+	}
+
+	return ts
+}
+
+// SetTextFrameTimestamp stores a timestamp with its precision in a
+// text frame. The zero timestamp removes the frame.
+func (t *Tag) SetTextFrameTimestamp(name FrameType, ts Timestamp) {
+	if ts.IsZero() {
+		t.RemoveFrames(name)
+		return
+	}
+
+	t.SetTextFrame(name, ts.String())
+}
+
+func (t *Tag) RecordingTime() Timestamp {
+	return t.GetTextFrameTimestamp("TDRC")
+}
+
+func (t *Tag) SetRecordingTime(ts Timestamp) {
+	t.SetTextFrameTimestamp("TDRC", ts)
+}
+
+func (t *Tag) OriginalReleaseTime() Timestamp {
+	return t.GetTextFrameTimestamp("TDOR")
+}
+
+func (t *Tag) SetOriginalReleaseTime(ts Timestamp) {
+	t.SetTextFrameTimestamp("TDOR", ts)
+}
+
+func (t *Tag) ReleaseTime() Timestamp {
+	return t.GetTextFrameTimestamp("TDRL")
+}
+
+func (t *Tag) SetReleaseTime(ts Timestamp) {
+	t.SetTextFrameTimestamp("TDRL", ts)
+}
+
+func (t *Tag) EncodingTime() Timestamp {
+	return t.GetTextFrameTimestamp("TDEN")
+}
+
+func (t *Tag) SetEncodingTime(ts Timestamp) {
+	t.SetTextFrameTimestamp("TDEN", ts)
+}
+
+func (t *Tag) TaggingTime() Timestamp {
+	return t.GetTextFrameTimestamp("TDTG")
+}
+
+func (t *Tag) SetTaggingTime(ts Timestamp) {
+	t.SetTextFrameTimestamp("TDTG", ts)
+}
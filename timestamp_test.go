@@ -0,0 +1,67 @@
+package id3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampRoundTrip(t *testing.T) {
+	tests := []struct {
+		in        string
+		precision Precision
+	}{
+		{"2009", PrecisionYear},
+		{"2009-11", PrecisionMonth},
+		{"2009-11-10", PrecisionDay},
+		{"2009-11-10T23", PrecisionHour},
+		{"2009-11-10T23:01", PrecisionMinute},
+		{"2009-11-10T23:01:02", PrecisionSecond},
+	}
+
+	for _, test := range tests {
+		ts, err := ParseTimestamp(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts.Precision != test.precision {
+			t.Errorf("%q parsed with precision %d, expected %d", test.in, ts.Precision, test.precision)
+		}
+		if s := ts.String(); s != test.in {
+			t.Errorf("%q formatted as %q", test.in, s)
+		}
+
+		tag := NewTag()
+		tag.SetTextFrame("TDRL", test.in)
+		if res := tag.ReleaseTime(); res != ts {
+			t.Errorf("ReleaseTime is %v, expected %v", res, ts)
+		}
+		tag.SetRecordingTime(ts)
+		if res := tag.GetTextFrame("TDRC"); res != test.in {
+			t.Errorf("SetRecordingTime wrote %q, expected %q", res, test.in)
+		}
+	}
+
+	if _, err := ParseTimestamp("2009-1"); err == nil {
+		t.Error("Parsing malformed timestamp succeeded")
+	}
+}
+
+func TestNewTimestamp(t *testing.T) {
+	now := time.Date(2009, 11, 10, 23, 1, 2, 3, time.UTC)
+	if s := NewTimestamp(now, PrecisionDay).String(); s != "2009-11-10" {
+		t.Fatalf("Got %q, expected %q", s, "2009-11-10")
+	}
+	if ts := NewTimestamp(now, PrecisionMonth); ts.Time != time.Date(2009, 11, 1, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("Components beyond the precision weren't dropped: %s", ts.Time)
+	}
+
+	tag := NewTag()
+	tag.SetTextFrame("TDEN", "garbage")
+	if !tag.EncodingTime().IsZero() {
+		t.Fatal("Malformed timestamp didn't result in zero timestamp")
+	}
+	tag.SetEncodingTime(Timestamp{})
+	if tag.HasFrame("TDEN") {
+		t.Fatal("Setting zero timestamp didn't remove frame")
+	}
+}
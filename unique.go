@@ -0,0 +1,239 @@
+package id3
+
+import (
+	"bytes"
+	"strings"
+)
+
+// A DuplicatePolicy determines how Parse handles frames that may not
+// coexist according to the specification, like two TIT2 frames or two
+// comments with the same language and description.
+type DuplicatePolicy int
+
+const (
+	// KeepFirst keeps the first of the duplicate frames.
+	KeepFirst DuplicatePolicy = iota
+	// KeepLast keeps the last of the duplicate frames.
+	KeepLast
+	// MergeDuplicates combines the values of duplicate text and user
+	// text frames into one frame with multiple values, and keeps the
+	// first of other duplicate frames.
+	MergeDuplicates
+)
+
+// uniqueKeys maps frame IDs to functions returning the fields that
+// have to be unique among frames with that ID. Text and URL frames not
+// listed here, as well as the frames in singleFrames, may only appear
+// once, whatever their type.
+var uniqueKeys = map[FrameType]func(frame Frame) (string, bool){
+	"TXXX": func(frame Frame) (string, bool) {
+		f, ok := frame.(UserTextInformationFrame)
+		return f.Description, ok
+	},
+	"WXXX": func(frame Frame) (string, bool) {
+		f, ok := frame.(UserDefinedURLLinkFrame)
+		return f.Description, ok
+	},
+	"COMM": func(frame Frame) (string, bool) {
+		f, ok := frame.(CommentFrame)
+		return f.Language + "\x00" + f.Description, ok
+	},
+	"USLT": func(frame Frame) (string, bool) {
+		f, ok := frame.(UnsynchronisedLyricsFrame)
+		return f.Language + "\x00" + f.Description, ok
+	},
+	"APIC": func(frame Frame) (string, bool) {
+		f, ok := frame.(PictureFrame)
+		return f.Description, ok
+	},
+	"UFID": func(frame Frame) (string, bool) {
+		f, ok := frame.(UniqueFileIdentifierFrame)
+		return f.Owner, ok
+	},
+	"PRIV": func(frame Frame) (string, bool) {
+		f, ok := frame.(PrivateFrame)
+		return string(f.Owner), ok
+	},
+	"POPM": leadingString, // email address
+	"AENC": leadingString, // owner
+	"WCOM": func(frame Frame) (string, bool) {
+		f, ok := frame.(URLLinkFrame)
+		return f.URL, ok
+	},
+	"WOAR": func(frame Frame) (string, bool) {
+		f, ok := frame.(URLLinkFrame)
+		return f.URL, ok
+	},
+}
+
+// singleFrames are frames that may only appear once per tag,
+// regardless of their content.
+var singleFrames = map[FrameType]bool{
+	"ASPI": true,
+	"ETCO": true,
+	"MCDI": true,
+	"MLLT": true,
+	"OWNE": true,
+	"PCNT": true,
+	"POSS": true,
+	"RBUF": true,
+	"RVRB": true,
+	"SEEK": true,
+	"SYTC": true,
+}
+
+// leadingString returns the null terminated string at the beginning
+// of an unsupported frame.
+func leadingString(frame Frame) (string, bool) {
+	f, ok := frame.(UnsupportedFrame)
+	if !ok {
+		return "", false
+	}
+
+	return string(bytes.SplitN(f.Data, nul, 2)[0]), true
+}
+
+// frameKey returns the fields of a frame that have to be unique among
+// frames with the same ID, and whether there is such a restriction.
+func frameKey(frame Frame) (string, bool) {
+	id := frame.ID()
+	if key, ok := uniqueKeys[id]; ok {
+		return key(frame)
+	}
+
+	// Frames that cannot be decoded, like compressed ones, are
+	// identified by their ID alone, too.
+	if id != "" && (id[0] == 'T' || id[0] == 'W') {
+		return "", true
+	}
+
+	return "", singleFrames[id]
+}
+
+// findFrame returns the index of the frame with the given ID and
+// unique key, or -1 if there is none.
+func (t *Tag) findFrame(id FrameType, key string) int {
+	for i, frame := range t.Frames[id] {
+		if k, ok := frameKey(frame); ok && k == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// addFrame adds a parsed frame to the tag, resolving duplicates
+// according to policy. It reports false if the frame was a duplicate.
+func (t *Tag) addFrame(frame Frame, policy DuplicatePolicy) bool {
+	id := frame.ID()
+	key, unique := frameKey(frame)
+	i := -1
+	if unique {
+		i = t.findFrame(id, key)
+	}
+	if i < 0 {
+		t.Frames[id] = append(t.Frames[id], frame)
+		return true
+	}
+
+	action := "kept first"
+	switch policy {
+	case KeepLast:
+		t.Frames[id][i] = frame
+		action = "kept last"
+	case MergeDuplicates:
+		t.Frames[id][i] = mergeFrame(t.Frames[id][i], frame)
+		action = "merged"
+	}
+	t.logger().Warn("duplicate frame", "frame", id, "action", action)
+	return false
+}
+
+// mergeFrame adds the values of a text or user text frame to another
+// one of the same kind. Other frames aren't merged.
+func mergeFrame(dst, src Frame) Frame {
+	switch f := dst.(type) {
+	case TextInformationFrame:
+		if src, ok := src.(TextInformationFrame); ok {
+			f.Text = mergeValues(f.Text, src.Text)
+		}
+		return f
+	case UserTextInformationFrame:
+		if src, ok := src.(UserTextInformationFrame); ok {
+			f.Text = mergeValues(f.Text, src.Text)
+		}
+		return f
+	}
+
+	return dst
+}
+
+// mergeValues combines null separated values, omitting values that
+// are already present.
+func mergeValues(a, b string) string {
+	values := strings.Split(a, "\x00")
+outer:
+	for _, value := range strings.Split(b, "\x00") {
+		for _, existing := range values {
+			if value == existing {
+				continue outer
+			}
+		}
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "\x00")
+}
+
+// setFrame replaces the frame with the given ID and unique key, or
+// adds one if there is none, using build to create the frame. The
+// status flags of a replaced frame are preserved, read-only frames
+// aren't modified, and other frames with the same key are removed.
+func (t *Tag) setFrame(id FrameType, key string, build func(header FrameHeader) Frame) {
+	header := FrameHeader{id: id}
+	i := t.findFrame(id, key)
+	if i < 0 {
+		t.Frames[id] = append(t.Frames[id], build(header))
+		return
+	}
+
+	frames := t.Frames[id]
+	if frameFlags(frames[i]).ReadOnly() {
+		t.logger().Debug("not modifying read-only frame", "frame", id, "action", "kept")
+		return
+	}
+	header.flags = frameFlags(frames[i]).status()
+	frames[i] = build(header)
+	t.removeDuplicates(id, i)
+}
+
+// removeDuplicates removes frames that may not coexist with the i-th
+// frame with the given ID.
+func (t *Tag) removeDuplicates(id FrameType, i int) {
+	frames := t.Frames[id]
+	key, _ := frameKey(frames[i])
+
+	kept := frames[:0]
+	for j, frame := range frames {
+		if k, ok := frameKey(frame); j != i && ok && k == key {
+			continue
+		}
+		kept = append(kept, frame)
+	}
+	t.Frames[id] = kept
+}
+
+// SetFrame adds a frame to the tag, replacing the frame it may not
+// coexist with according to the specification, if any. For example, a
+// TXXX frame replaces the TXXX frame with the same description, and a
+// TIT2 frame replaces the title. Read-only frames aren't replaced.
+func (t *Tag) SetFrame(frame Frame) {
+	id := frame.ID()
+	key, unique := frameKey(frame)
+	if !unique {
+		t.Frames[id] = append(t.Frames[id], frame)
+		return
+	}
+
+	t.setFrame(id, key, func(FrameHeader) Frame { return frame })
+}
@@ -0,0 +1,115 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	var body []byte
+	for _, frame := range [][]byte{
+		rawFrame("TPE1", 0, []byte("\x00First")),
+		rawFrame("TXXX", 0, []byte("\x00desc\x00one")),
+		rawFrame("COMM", 0, []byte("\x00eng\x00first")),
+		rawFrame("TPE1", 0, []byte("\x00Second")),
+		rawFrame("TXXX", 0, []byte("\x00desc\x00two")),
+		rawFrame("TXXX", 0, []byte("\x00other\x00three")),
+		rawFrame("COMM", 0, []byte("\x00eng\x00second")),
+	} {
+		body = append(body, frame...)
+	}
+	data := append(generateHeader(len(body), 0), body...)
+
+	tests := []struct {
+		policy  DuplicatePolicy
+		artist  string
+		user    string
+		comment string
+	}{
+		{KeepFirst, "First", "one", "first"},
+		{KeepLast, "Second", "two", "second"},
+		{MergeDuplicates, "First\x00Second", "one\x00two", "first"},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.Duplicates = test.policy
+		tag, err := ParseWithOptions(bytes.NewReader(data), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(tag.Frames["TPE1"]); n != 1 {
+			t.Errorf("Policy %d: %d TPE1 frames, expected 1", test.policy, n)
+		}
+		if n := len(tag.Frames["TXXX"]); n != 2 {
+			t.Errorf("Policy %d: %d TXXX frames, expected 2", test.policy, n)
+		}
+		if n := len(tag.Frames["COMM"]); n != 1 {
+			t.Errorf("Policy %d: %d COMM frames, expected 1", test.policy, n)
+		}
+
+		artist := tag.GetTextFrame("TPE1")
+		user := tag.GetTextFrame("TXXX:desc")
+		comment := tag.Frames["COMM"][0].Value()
+		if artist != test.artist || user != test.user || comment != test.comment {
+			t.Errorf("Policy %d: got %q, %q, %q, expected %q, %q, %q", test.policy,
+				artist, user, comment, test.artist, test.user, test.comment)
+		}
+	}
+}
+
+func TestSettersReplaceDuplicates(t *testing.T) {
+	tag := NewTag()
+	tag.Frames["TIT2"] = []Frame{
+		TextInformationFrame{FrameHeader: FrameHeader{id: "TIT2"}, Text: "One"},
+		TextInformationFrame{FrameHeader: FrameHeader{id: "TIT2"}, Text: "Two"},
+	}
+	tag.SetTitle("Title")
+	if n := len(tag.Frames["TIT2"]); n != 1 || tag.Title() != "Title" {
+		t.Fatalf("Got %d TIT2 frames with title %q", n, tag.Title())
+	}
+
+	tag.SetTextFrame("TXXX:a", "1")
+	tag.SetTextFrame("TXXX:b", "2")
+	tag.SetTextFrame("TXXX:a", "3")
+	if n := len(tag.Frames["TXXX"]); n != 2 {
+		t.Fatalf("Got %d TXXX frames, expected 2", n)
+	}
+	if a, b := tag.GetTextFrame("TXXX:a"), tag.GetTextFrame("TXXX:b"); a != "3" || b != "2" {
+		t.Fatalf("Got %q and %q, expected %q and %q", a, b, "3", "2")
+	}
+
+	tag.SetComments([]Comment{
+		{Language: "eng", Text: "first"},
+		{Language: "eng", Text: "second"},
+	})
+	if n := len(tag.Frames["COMM"]); n != 1 || tag.Frames["COMM"][0].Value() != "second" {
+		t.Fatalf("Unexpected comments %v", tag.Frames["COMM"])
+	}
+
+	tag.SetFrame(UniqueFileIdentifierFrame{FrameHeader: FrameHeader{id: "UFID"}, Owner: "owner", Identifier: []byte("1")})
+	tag.SetFrame(UniqueFileIdentifierFrame{FrameHeader: FrameHeader{id: "UFID"}, Owner: "other", Identifier: []byte("2")})
+	tag.SetFrame(UniqueFileIdentifierFrame{FrameHeader: FrameHeader{id: "UFID"}, Owner: "owner", Identifier: []byte("3")})
+	if n := len(tag.Frames["UFID"]); n != 2 || tag.Frames["UFID"][0].Value() != "3" {
+		t.Fatalf("Unexpected UFID frames %v", tag.Frames["UFID"])
+	}
+}
+
+func TestSettersReplaceUndecodedFrames(t *testing.T) {
+	raw := rawFrame("TIT2", flagCompressed|0x4000, []byte("compressed"))
+	data := append(generateHeader(len(raw), 0), raw...)
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.SetTitle("Title")
+	frames := tag.Frames["TIT2"]
+	if len(frames) != 1 || tag.Title() != "Title" {
+		t.Fatalf("Got %d TIT2 frames with title %q", len(frames), tag.Title())
+	}
+	if flags := frameFlags(frames[0]); flags != 0x4000 {
+		t.Fatalf("Got flags %#04x, expected %#04x", uint16(flags), 0x4000)
+	}
+}
@@ -0,0 +1,237 @@
+package id3
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An UpgradeAction describes a change made while upgrading a tag to
+// v2.4.
+type UpgradeAction struct {
+	// The frames that were read, replaced or removed.
+	Frames      []FrameType
+	Description string
+}
+
+func (a UpgradeAction) String() string {
+	ids := make([]string, len(a.Frames))
+	for i, id := range a.Frames {
+		ids[i] = string(id)
+	}
+
+	return strings.Join(ids, ", ") + ": " + a.Description
+}
+
+// record logs an upgrade action and adds it to t.Upgrades.
+func (t *Tag) record(frames []FrameType, format string, args ...interface{}) {
+	action := UpgradeAction{Frames: frames, Description: fmt.Sprintf(format, args...)}
+	t.logger().Debug("upgrading frames", "frame", action.Frames, "action", action.Description)
+	t.Upgrades = append(t.Upgrades, action)
+}
+
+// upgrade upgrades tags from an older version to IDv2.4. It should
+// only be called for files that use an older version.
+func (t *Tag) upgrade() {
+	t.upgradeRecordingTime()
+	t.upgradeOriginalReleaseTime()
+	t.upgradeRecordingDates()
+
+	for name := range t.Frames {
+		switch name {
+		case "TLAN", "TCON", "TPE1", "TOPE", "TCOM", "TEXT", "TOLY":
+			if text := t.GetTextFrame(name); strings.Contains(text, "/") {
+				t.SetTextFrameSlice(name, strings.Split(text, "/"))
+				t.record([]FrameType{name}, "replaced / with null bytes as value separator")
+			}
+		}
+	}
+	// TODO EQUA → EQU2
+	// TODO IPL → TMCL, TIPL
+	// TODO RVAD → RVA2
+}
+
+// upgradeRecordingTime replaces TYER (yyyy), TDAT (DDMM) and TIME
+// (HHMM) with TDRC, with a precision depending on which of them are
+// present and valid. If TYER contains more than the year, like
+// "1999/2000", the first year in it is used. Without a year, the
+// frames are kept as they are.
+func (t *Tag) upgradeRecordingTime() {
+	var frames []FrameType
+	for _, id := range []FrameType{"TYER", "TDAT", "TIME"} {
+		if t.HasFrame(id) {
+			frames = append(frames, id)
+		}
+	}
+	if len(frames) == 0 {
+		return
+	}
+
+	text := t.GetTextFrame("TYER")
+	year, yearOK := parseDigits(text, 4)
+	if !yearOK {
+		if match := yearRegexp.FindString(text); match != "" {
+			year, _ = strconv.Atoi(match)
+			yearOK = true
+		}
+	}
+	if !yearOK {
+		t.record(frames, "kept, no year found in TYER %q", text)
+		return
+	}
+
+	date, dateOK := parseDigits(t.GetTextFrame("TDAT"), 4)
+	tim, timeOK := parseDigits(t.GetTextFrame("TIME"), 4)
+	day, month := date/100, date%100
+	hour, minute := tim/100, tim%100
+	dateOK = dateOK && month >= 1 && month <= 12 && day >= 1 && day <= daysIn(time.Month(month), year)
+	timeOK = timeOK && hour <= 23 && minute <= 59
+
+	for _, id := range frames {
+		t.RemoveFrames(id)
+	}
+
+	var ts Timestamp
+	switch {
+	case dateOK && timeOK:
+		ts = Timestamp{time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC), PrecisionMinute}
+	case dateOK:
+		ts = Timestamp{time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), PrecisionDay}
+	default:
+		ts = Timestamp{time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), PrecisionYear}
+	}
+
+	if t.HasFrame("TDRC") {
+		t.record(frames, "removed, TDRC already present")
+		return
+	}
+	t.SetRecordingTime(ts)
+	t.record(frames, "replaced with TDRC %s", ts)
+}
+
+// upgradeOriginalReleaseTime replaces XDOR (a full date, used by some
+// v2.3 taggers) or TORY (yyyy) with TDOR.
+func (t *Tag) upgradeOriginalReleaseTime() {
+	var (
+		frames []FrameType
+		ts     Timestamp
+	)
+
+	if t.HasFrame("XDOR") {
+		frames = append(frames, "XDOR")
+		if xdor, err := ParseTimestamp(t.legacyText("XDOR")); err == nil {
+			ts = xdor
+		}
+	}
+	if t.HasFrame("TORY") {
+		frames = append(frames, "TORY")
+		if year, ok := parseDigits(t.GetTextFrame("TORY"), 4); ok && ts.IsZero() {
+			ts = Timestamp{time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), PrecisionYear}
+		}
+	}
+	if len(frames) == 0 {
+		return
+	}
+
+	for _, id := range frames {
+		t.RemoveFrames(id)
+	}
+
+	switch {
+	case ts.IsZero():
+		t.record(frames, "removed, no valid date")
+	case t.HasFrame("TDOR"):
+		t.record(frames, "removed, TDOR already present")
+	default:
+		t.SetOriginalReleaseTime(ts)
+		t.record(frames, "replaced with TDOR %s", ts)
+	}
+}
+
+var (
+	isoDateRegexp = regexp.MustCompile(`\b\d{4}-\d{2}(-\d{2})?\b`)
+	yearRegexp    = regexp.MustCompile(`\b\d{4}\b`)
+)
+
+// upgradeRecordingDates tries to convert TRDA, a free form list of
+// recording dates, to TDRL. The frame is kept, though not written, if
+// it contains no recognizable date.
+func (t *Tag) upgradeRecordingDates() {
+	if !t.HasFrame("TRDA") {
+		return
+	}
+	frames := []FrameType{"TRDA"}
+	text := t.GetTextFrame("TRDA")
+
+	var ts Timestamp
+	for _, match := range isoDateRegexp.FindAllString(text, -1) {
+		if res, err := ParseTimestamp(match); err == nil {
+			ts = res
+			break
+		}
+	}
+	if ts.IsZero() {
+		for _, layout := range []string{"2 January 2006", "January 2, 2006", "January 2 2006", "2 Jan 2006", "Jan 2, 2006"} {
+			if res, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				ts = Timestamp{res, PrecisionDay}
+				break
+			}
+		}
+	}
+	if ts.IsZero() {
+		if match := yearRegexp.FindString(text); match != "" {
+			year, _ := strconv.Atoi(match)
+			ts = Timestamp{time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), PrecisionYear}
+		}
+	}
+
+	switch {
+	case ts.IsZero():
+		t.record(frames, "kept but won't be written, no date found in %q", text)
+	case t.HasFrame("TDRL"):
+		t.RemoveFrames("TRDA")
+		t.record(frames, "removed, TDRL already present")
+	default:
+		t.RemoveFrames("TRDA")
+		t.SetReleaseTime(ts)
+		t.record(frames, "replaced with TDRL %s, derived from %q", ts, text)
+	}
+}
+
+// legacyText returns the text of a frame that is a text frame in
+// v2.3, but not in v2.4, and therefore wasn't read as one.
+func (t *Tag) legacyText(id FrameType) string {
+	frames := t.Frames[id]
+	if len(frames) == 0 {
+		return ""
+	}
+
+	f, ok := frames[0].(UnsupportedFrame)
+	if !ok || len(f.Data) == 0 || f.Data[0] > byte(utf8) {
+		return frames[0].Value()
+	}
+
+	return string(Encoding(f.Data[0]).toUTF8(f.Data[1:]))
+}
+
+// parseDigits parses a number consisting of exactly n digits.
+func parseDigits(s string, n int) (int, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) != n {
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	v, _ := strconv.Atoi(s)
+	return v, true
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
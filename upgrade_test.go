@@ -0,0 +1,100 @@
+package id3
+
+import (
+	"bytes"
+	"testing"
+)
+
+// parseV23 parses a v2.3 tag consisting of the given text frames.
+func parseV23(t *testing.T, frames map[FrameType]string) *Tag {
+	var body []byte
+	for id, text := range frames {
+		body = append(body, rawFrame(string(id), 0, append([]byte{0}, text...))...)
+	}
+	data := append(generateHeader(len(body), 0), body...)
+	data[3] = 3
+
+	tag, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tag
+}
+
+func TestUpgradeDates(t *testing.T) {
+	tests := []struct {
+		in   map[FrameType]string
+		tdrc string
+		tdor string
+		tdrl string
+	}{
+		{map[FrameType]string{"TYER": "2009"}, "2009", "", ""},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "1011"}, "2009-11-10", "", ""},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "1011", "TIME": "2301"}, "2009-11-10T23:01", "", ""},
+		{map[FrameType]string{"TYER": "2009", "TIME": "2301"}, "2009", "", ""},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "3102"}, "2009", "", ""},
+		{map[FrameType]string{"TYER": "1999/2000", "TDAT": "1011"}, "1999-11-10", "", ""},
+		{map[FrameType]string{"TYER": "c. 1975"}, "1975", "", ""},
+		{map[FrameType]string{"TORY": "1999"}, "", "1999", ""},
+		{map[FrameType]string{"XDOR": "1999-05-04", "TORY": "1999"}, "", "1999-05-04", ""},
+		{map[FrameType]string{"TRDA": "4th-7th June, 12th June 2001"}, "", "", "2001"},
+		{map[FrameType]string{"TRDA": "2001-06-04, 2001-06-12"}, "", "", "2001-06-04"},
+		{map[FrameType]string{"TRDA": "June 4, 2001"}, "", "", "2001-06-04"},
+	}
+
+	for _, test := range tests {
+		tag := parseV23(t, test.in)
+		if res := tag.RecordingTime().String(); res != test.tdrc {
+			t.Errorf("%v: TDRC is %q, expected %q", test.in, res, test.tdrc)
+		}
+		if res := tag.OriginalReleaseTime().String(); res != test.tdor {
+			t.Errorf("%v: TDOR is %q, expected %q", test.in, res, test.tdor)
+		}
+		if res := tag.ReleaseTime().String(); res != test.tdrl {
+			t.Errorf("%v: TDRL is %q, expected %q", test.in, res, test.tdrl)
+		}
+		for _, id := range []FrameType{"TYER", "TDAT", "TIME", "TORY", "XDOR", "TRDA"} {
+			if tag.HasFrame(id) {
+				t.Errorf("%v: %s wasn't removed", test.in, id)
+			}
+		}
+		if len(tag.Upgrades) == 0 {
+			t.Errorf("%v: no upgrade actions recorded", test.in)
+		}
+	}
+}
+
+func TestUpgradeKeepsInvalidYears(t *testing.T) {
+	for _, in := range []map[FrameType]string{
+		{"TYER": "99", "TDAT": "1011", "TIME": "2301"},
+		{"TYER": "unknown"},
+		{"TDAT": "1011"},
+	} {
+		tag := parseV23(t, in)
+		for id, text := range in {
+			if res := tag.GetTextFrame(id); res != text {
+				t.Errorf("%v: %s is %q, expected it to be kept", in, id, res)
+			}
+		}
+		if tag.HasFrame("TDRC") {
+			t.Errorf("%v: TDRC was added", in)
+		}
+		if len(tag.Upgrades) != 1 {
+			t.Errorf("%v: recorded actions %v, expected 1", in, tag.Upgrades)
+		}
+	}
+}
+
+func TestUpgradeKeepsUnknownRecordingDates(t *testing.T) {
+	tag := parseV23(t, map[FrameType]string{"TRDA": "sometime", "TPE1": "A/B"})
+	if !tag.HasFrame("TRDA") || tag.HasFrame("TDRL") {
+		t.Fatal("TRDA without a date should be kept")
+	}
+	if artists := tag.Artists(); len(artists) != 2 {
+		t.Fatalf("Artists are %q, expected two", artists)
+	}
+	if len(tag.Upgrades) != 2 {
+		t.Fatalf("Recorded actions %v, expected 2", tag.Upgrades)
+	}
+}
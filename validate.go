@@ -0,0 +1,390 @@
+package id3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity classifies validation issues.
+type Severity int
+
+const (
+	// SeverityWarning marks data that is valid but unusual, like
+	// non-standard frames or upper case language codes.
+	SeverityWarning Severity = iota
+	// SeverityError marks data that violates the specification.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Unknown severity %d", int(s))
+	}
+}
+
+// An Issue describes a problem found by Tag.Validate.
+type Issue struct {
+	Frame    FrameType // Empty for issues of the whole tag
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	if i.Frame == "" {
+		return fmt.Sprintf("tag: %s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Frame, i.Severity, i.Message)
+}
+
+// A Change describes how Tag.Sanitize resolved an issue.
+type Change struct {
+	Issue
+	Action string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s (%s)", c.Issue, c.Action)
+}
+
+// obsoleteFrames are frames of ID3v2.3 that don't exist in v2.4.
+var obsoleteFrames = map[FrameType]bool{
+	"EQUA": true,
+	"IPLS": true,
+	"RVAD": true,
+	"TDAT": true,
+	"TIME": true,
+	"TORY": true,
+	"TRDA": true,
+	"TSIZ": true,
+	"TYER": true,
+	"XDOR": true,
+}
+
+// timestampFrames are text frames containing timestamps.
+var timestampFrames = map[FrameType]bool{
+	"TDEN": true,
+	"TDOR": true,
+	"TDRC": true,
+	"TDRL": true,
+	"TDTG": true,
+}
+
+type validator struct {
+	fix          bool
+	restrictions *Restrictions
+	changes      []Change
+}
+
+// report records an issue. action describes how Sanitize resolves it,
+// and is empty if it doesn't.
+func (v *validator) report(id FrameType, severity Severity, action string, format string, args ...interface{}) {
+	if v.fix && action == "" {
+		// Issues that Sanitize leaves alone aren't changes.
+		return
+	}
+
+	change := Change{Issue: Issue{
+		Frame:    id,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	}}
+	if v.fix {
+		change.Action = action
+	}
+	v.changes = append(v.changes, change)
+}
+
+// Validate checks whether the tags are conforming to the
+// specification and returns the issues it found.
+//
+// This entails checking whether only frames that are covered by the
+// specification are present, whether all values are within valid
+// ranges, whether frames that must be unique are, and whether the tag
+// meets its Restrictions, if any. Parse doesn't read restrictions from
+// files, as it rejects tags with an extended header, so Restrictions
+// have to be set by the caller.
+//
+// It is well possible that reading existing files will result in
+// invalid tags.
+//
+// Save and Encode only validate tags if Options.Strict is set, and
+// fail if Validate reports errors then. Otherwise, they happily write
+// invalid tags.
+//
+// Assuming that the original file was valid and that only the
+// getter/setter methods were used the generated tags should always be
+// valid.
+func (t *Tag) Validate() []Issue {
+	changes := t.check(false)
+	issues := make([]Issue, len(changes))
+	for i, change := range changes {
+		issues[i] = change.Issue
+	}
+
+	return issues
+}
+
+// Sanitize fixes the issues that Validate would report, removing
+// frames that cannot be fixed, and returns what it changed. Of
+// duplicate frames, the first one is kept. Text exceeding the
+// restricted length is truncated; tags with too many frames or bytes
+// are left alone.
+func (t *Tag) Sanitize() []Change {
+	return t.check(true)
+}
+
+func (t *Tag) check(fix bool) []Change {
+	v := &validator{fix: fix, restrictions: t.Restrictions}
+
+	ids := make([]FrameType, 0, len(t.Frames))
+	for id := range t.Frames {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if !v.checkID(id) {
+			if fix {
+				t.RemoveFrames(id)
+			}
+			continue
+		}
+
+		var (
+			kept  []Frame
+			seen  = make(map[string]bool)
+			icons = make(map[PictureType]bool)
+		)
+		for _, frame := range t.Frames[id] {
+			frame, ok := v.checkFrame(frame)
+			if !ok {
+				continue
+			}
+			if v.restrictions != nil {
+				if frame, ok = v.checkRestrictions(frame); !ok {
+					continue
+				}
+			}
+
+			if key, unique := frameKey(frame); unique {
+				if seen[key] {
+					v.report(id, SeverityError, "removed duplicate",
+						"frame must be unique%s", describeKey(frame))
+					continue
+				}
+				seen[key] = true
+			}
+
+			if pic, ok := frame.(PictureFrame); ok && (pic.PictureType == 1 || pic.PictureType == 2) {
+				if icons[pic.PictureType] {
+					v.report(id, SeverityError, "removed duplicate",
+						"only one picture of type %q is allowed", PictureTypes[pic.PictureType])
+					continue
+				}
+				icons[pic.PictureType] = true
+			}
+
+			kept = append(kept, frame)
+		}
+
+		if fix {
+			if len(kept) == 0 {
+				t.RemoveFrames(id)
+			} else {
+				t.Frames[id] = kept
+			}
+		}
+	}
+
+	if v.restrictions != nil {
+		t.checkTagRestrictions(v)
+	}
+
+	return v.changes
+}
+
+// describeKey describes what makes a frame unique, for use in issue
+// messages.
+func describeKey(frame Frame) string {
+	switch f := frame.(type) {
+	case UserTextInformationFrame:
+		return fmt.Sprintf(" per description (%q)", f.Description)
+	case UserDefinedURLLinkFrame:
+		return fmt.Sprintf(" per description (%q)", f.Description)
+	case CommentFrame:
+		return fmt.Sprintf(" per language and description (%q, %q)", f.Language, f.Description)
+	case UnsynchronisedLyricsFrame:
+		return fmt.Sprintf(" per language and description (%q, %q)", f.Language, f.Description)
+	case PictureFrame:
+		return fmt.Sprintf(" per description (%q)", f.Description)
+	case UniqueFileIdentifierFrame:
+		return fmt.Sprintf(" per owner (%q)", f.Owner)
+	case URLLinkFrame:
+		if f.ID() == "WCOM" || f.ID() == "WOAR" {
+			return fmt.Sprintf(" per URL (%q)", f.URL)
+		}
+	case PrivateFrame:
+		return fmt.Sprintf(" per owner (%q)", f.Owner)
+	}
+
+	return ""
+}
+
+// checkID checks whether frames with the ID are allowed in a v2.4
+// tag.
+func (v *validator) checkID(id FrameType) bool {
+	if obsoleteFrames[id] {
+		v.report(id, SeverityError, "removed frame", "frame doesn't exist in ID3v2.4")
+		return false
+	}
+
+	if _, ok := FrameNames[id]; ok {
+		return true
+	}
+
+	switch {
+	case id != "" && (id[0] == 'X' || id[0] == 'Y' || id[0] == 'Z'):
+		v.report(id, SeverityWarning, "", "experimental frame")
+		return true
+	case isRegistered(id):
+		v.report(id, SeverityWarning, "", "non-standard frame handled by a codec")
+		return true
+	default:
+		v.report(id, SeverityError, "removed frame", "unknown frame")
+		return false
+	}
+}
+
+func isRegistered(id FrameType) bool {
+	_, ok := lookupFrameCodec(id)
+	return ok
+}
+
+// checkFrame checks the values of a frame. It returns the frame,
+// fixed if fixing is enabled, and whether to keep it.
+func (v *validator) checkFrame(frame Frame) (Frame, bool) {
+	id := frame.ID()
+
+	switch f := frame.(type) {
+	case TextInformationFrame:
+		switch {
+		case id == "TSRC":
+			return v.checkISRC(f)
+		case id == "TLAN":
+			return v.checkLanguages(f)
+		case timestampFrames[id]:
+			for _, value := range strings.Split(f.Text, "\x00") {
+				if _, err := parseTime(value); err != nil {
+					v.report(id, SeverityError, "removed frame", "malformed timestamp %q", value)
+					return frame, false
+				}
+			}
+		}
+	case CommentFrame:
+		f.Language = v.checkLanguage(id, f.Language)
+		return f, true
+	case UnsynchronisedLyricsFrame:
+		f.Language = v.checkLanguage(id, f.Language)
+		return f, true
+	case PictureFrame:
+		if int(f.PictureType) >= len(PictureTypes) {
+			v.report(id, SeverityError, "set picture type to \"Other\"",
+				"picture type %d out of range", f.PictureType)
+			f.PictureType = 0
+		}
+		return f, true
+	}
+
+	return frame, true
+}
+
+// checkISRC checks that a TSRC frame contains a 12 character ISRC.
+// Hyphens, spaces and lower case letters, as in the commonly printed
+// form, are fixed.
+func (v *validator) checkISRC(f TextInformationFrame) (Frame, bool) {
+	if isISRC(f.Text) {
+		return f, true
+	}
+
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(f.Text))
+	if !isISRC(normalized) {
+		v.report(f.ID(), SeverityError, "removed frame", "invalid ISRC %q", f.Text)
+		return f, false
+	}
+
+	v.report(f.ID(), SeverityError, fmt.Sprintf("changed to %q", normalized),
+		"ISRC %q must consist of 12 upper case letters and digits", f.Text)
+	f.Text = normalized
+	return f, true
+}
+
+func isISRC(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkLanguages checks the language codes of a TLAN frame, removing
+// invalid ones.
+func (v *validator) checkLanguages(f TextInformationFrame) (Frame, bool) {
+	var valid []string
+	for _, code := range strings.Split(f.Text, "\x00") {
+		if !isLanguage(strings.ToLower(code)) {
+			v.report(f.ID(), SeverityError, "removed language code", "invalid language code %q", code)
+			continue
+		}
+		valid = append(valid, v.checkLanguage(f.ID(), code))
+	}
+
+	if len(valid) == 0 {
+		return f, false
+	}
+
+	f.Text = strings.Join(valid, "\x00")
+	return f, true
+}
+
+// checkLanguage checks an ISO-639-2 language code and returns the
+// fixed code. Invalid codes are replaced with "XXX", which denotes an
+// unknown language.
+func (v *validator) checkLanguage(id FrameType, code string) string {
+	switch {
+	case code == "XXX" || isLanguage(code):
+		return code
+	case isLanguage(strings.ToLower(code)):
+		v.report(id, SeverityWarning, "converted to lower case",
+			"language code %q should be lower case", code)
+		return strings.ToLower(code)
+	default:
+		v.report(id, SeverityError, `replaced with "XXX"`, "invalid language code %q", code)
+		return "XXX"
+	}
+}
+
+func isLanguage(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+
+	for _, c := range code {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
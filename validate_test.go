@@ -0,0 +1,148 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestValidateAndSanitize(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetTextFrame("TSRC", "us-s1z-99-00001")
+	tag.SetTextFrame("TYER", "2001")
+	tag.SetTextFrame("TDRC", "yesterday")
+	tag.SetTextFrameSlice("TLAN", []string{"ENG", "german"})
+	tag.Frames["ABCD"] = []Frame{UnsupportedFrame{FrameHeader: FrameHeader{id: "ABCD"}}}
+	tag.Frames["XABC"] = []Frame{UnsupportedFrame{FrameHeader: FrameHeader{id: "XABC"}}}
+	tag.Frames["COMM"] = []Frame{
+		CommentFrame{FrameHeader: FrameHeader{id: "COMM"}, Language: "en", Text: "first"},
+		CommentFrame{FrameHeader: FrameHeader{id: "COMM"}, Language: "XXX", Text: "second"},
+	}
+	tag.Frames["APIC"] = []Frame{
+		PictureFrame{FrameHeader: FrameHeader{id: "APIC"}, PictureType: 1, Description: "a"},
+		PictureFrame{FrameHeader: FrameHeader{id: "APIC"}, PictureType: 1, Description: "b"},
+		PictureFrame{FrameHeader: FrameHeader{id: "APIC"}, PictureType: 200, Description: "c"},
+	}
+
+	expected := []Issue{
+		{"ABCD", SeverityError, "unknown frame"},
+		{"APIC", SeverityError, `only one picture of type "32x32 pixels 'file icon' (PNG only)" is allowed`},
+		{"APIC", SeverityError, "picture type 200 out of range"},
+		{"COMM", SeverityError, `invalid language code "en"`},
+		{"COMM", SeverityError, `frame must be unique per language and description ("XXX", "")`},
+		{"TDRC", SeverityError, `malformed timestamp "yesterday"`},
+		{"TLAN", SeverityWarning, `language code "ENG" should be lower case`},
+		{"TLAN", SeverityError, `invalid language code "german"`},
+		{"TSRC", SeverityError, `ISRC "us-s1z-99-00001" must consist of 12 upper case letters and digits`},
+		{"TYER", SeverityError, "frame doesn't exist in ID3v2.4"},
+		{"XABC", SeverityWarning, "experimental frame"},
+	}
+	issues := tag.Validate()
+	if len(issues) != len(expected) {
+		t.Fatalf("Got issues %v, expected %v", issues, expected)
+	}
+	for i := range issues {
+		if issues[i] != expected[i] {
+			t.Errorf("Issue %d is %v, expected %v", i, issues[i], expected[i])
+		}
+	}
+
+	changes := tag.Sanitize()
+	if len(changes) != len(expected)-1 {
+		t.Fatalf("Got changes %v", changes)
+	}
+	if issues := tag.Validate(); len(issues) != 1 || issues[0].Frame != "XABC" {
+		t.Fatalf("Issues remaining after sanitizing: %v", issues)
+	}
+
+	if isrc := tag.GetTextFrame("TSRC"); isrc != "USS1Z9900001" {
+		t.Errorf("TSRC is %q, expected %q", isrc, "USS1Z9900001")
+	}
+	if lang := tag.GetTextFrame("TLAN"); lang != "eng" {
+		t.Errorf("TLAN is %q, expected %q", lang, "eng")
+	}
+	for _, id := range []FrameType{"TYER", "TDRC", "ABCD"} {
+		if tag.HasFrame(id) {
+			t.Errorf("Frame %s wasn't removed", id)
+		}
+	}
+	if n := len(tag.Frames["COMM"]); n != 1 || tag.Frames["COMM"][0].Value() != "first" {
+		t.Errorf("Expected only the first comment to remain, got %v", tag.Frames["COMM"])
+	}
+	if n := len(tag.Frames["APIC"]); n != 2 || tag.Frames["APIC"][1].(PictureFrame).PictureType != 0 {
+		t.Errorf("Unexpected pictures after sanitizing: %v", tag.Frames["APIC"])
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	img := new(bytes.Buffer)
+	if err := png.Encode(img, image.NewGray(image.Rect(0, 0, 100, 50))); err != nil {
+		t.Fatal(err)
+	}
+
+	tag := NewTag()
+	tag.Encoding = AlwaysUTF16
+	tag.SetTitle("A title that is longer than thirty characters")
+	tag.SetTextFrameSlice("TPE1", []string{"One", "Two"})
+	tag.Frames["APIC"] = []Frame{
+		PictureFrame{FrameHeader: FrameHeader{id: "APIC"}, MIMEType: "image/gif", Description: "gif"},
+		PictureFrame{FrameHeader: FrameHeader{id: "APIC"}, MIMEType: "image/png", Description: "png", Data: img.Bytes()},
+	}
+	// 32 frames and 4 KB, UTF-8 or ISO-8859-1, 30 characters, PNG or
+	// JPEG, at most 64x64 pixels.
+	r := Restrictions(0xFE)
+	tag.Restrictions = &r
+
+	expected := []Issue{
+		{"APIC", SeverityError, `picture of type "image/gif" isn't PNG or JPEG`},
+		{"APIC", SeverityError, "picture is 100x50 pixels, larger than 64x64"},
+		{"TIT2", SeverityError, "text is 45 characters long, exceeding the limit of 30"},
+		{"", SeverityError, "encoding policy writes UTF-16 text"},
+	}
+	issues := tag.Validate()
+	if len(issues) != len(expected) {
+		t.Fatalf("Got issues %v, expected %v", issues, expected)
+	}
+	for i := range issues {
+		if issues[i] != expected[i] {
+			t.Errorf("Issue %d is %v, expected %v", i, issues[i], expected[i])
+		}
+	}
+
+	tag.Sanitize()
+	if issues := tag.Validate(); len(issues) != 0 {
+		t.Fatalf("Issues remaining after sanitizing: %v", issues)
+	}
+	if title := tag.Title(); title != "A title that is longer than th" {
+		t.Errorf("Title is %q", title)
+	}
+	if tag.HasFrame("APIC") || tag.Encoding != AlwaysUTF8 {
+		t.Error("Pictures weren't removed or encoding wasn't changed")
+	}
+
+	// Tags with too many frames cannot be sanitized.
+	for i := 0; i < 40; i++ {
+		tag.SetTextFrame(FrameType(fmt.Sprintf("TXXX:%d", i)), "value")
+	}
+	issues = tag.Validate()
+	if len(issues) != 1 || issues[0].Message != "tag has 42 frames, exceeding the limit of 32" {
+		t.Fatalf("Unexpected issues %v", issues)
+	}
+	if changes := tag.Sanitize(); len(changes) != 0 {
+		t.Fatalf("Unexpected changes %v", changes)
+	}
+
+	// Padding counts towards the size of the tag.
+	tag = NewTag()
+	tag.SetTitle("Title")
+	tag.Padding = &PaddingPolicy{Fixed: 4096}
+	tag.Restrictions = &r
+	issues = tag.Validate()
+	size := tagHeaderSize + tag.framesSize() + 4096
+	if msg := fmt.Sprintf("tag is %d bytes large, exceeding the limit of 4096", size); len(issues) != 1 || issues[0].Message != msg {
+		t.Fatalf("Unexpected issues %v", issues)
+	}
+}